@@ -0,0 +1,286 @@
+package ini
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// kinds of the document node
+const (
+	// comments, empty lines and the lines can't be parsed
+	triviaNode = iota
+	// section header
+	sectionNode
+	// key and its value
+	keyNode
+)
+
+// docNode keeps the original text of one element in the loaded content,
+// it is used to write back the content in preserve format mode
+type docNode struct {
+	kind int
+	// original text of the lines, includes the line terminators
+	lines []string
+	// original text of the comment lines before the section or key
+	leading []string
+	// comment and inline comment of the section or key when loaded
+	comment       string
+	inlineComment string
+	// the section of the node and its name when loaded
+	section     *Section
+	sectionName string
+	// name of the key
+	key string
+	// values of the key when loaded, used to detect if the values are changed
+	values []string
+	// true if the key is loaded in multi-value mode, the node is one of
+	// the values of the key
+	multiValue bool
+	// the text before and after the value in the key line
+	prefix string
+	suffix string
+}
+
+// return false if the section of the node is replaced in the ini
+func (node *docNode) alive(ini *Ini) bool {
+	return ini.sections[node.sectionName] == node.section
+}
+
+// return the line terminator of the last line of the node
+func (node *docNode) terminator() string {
+	last := node.lines[len(node.lines)-1]
+	if strings.HasSuffix(last, "\r\n") {
+		return "\r\n"
+	} else if strings.HasSuffix(last, "\n") {
+		return "\n"
+	}
+	return ""
+}
+
+// docWriter makes sure the text is written in a new line if the text
+// written before does not end with the line terminator
+type docWriter struct {
+	writer io.Writer
+	// true if the last written text does not end with the line terminator
+	pending bool
+	// the line terminator of the last written line, used by the new lines
+	eol string
+}
+
+func (w *docWriter) write(s string) error {
+	if len(s) <= 0 {
+		return nil
+	}
+	if w.pending {
+		if _, err := io.WriteString(w.writer, w.terminator()); err != nil {
+			return err
+		}
+	}
+	w.pending = !strings.HasSuffix(s, "\n")
+	if strings.HasSuffix(s, "\r\n") {
+		w.eol = "\r\n"
+	} else if !w.pending {
+		w.eol = "\n"
+	}
+	_, err := io.WriteString(w.writer, s)
+	return err
+}
+
+func (w *docWriter) terminator() string {
+	if len(w.eol) <= 0 {
+		return "\n"
+	}
+	return w.eol
+}
+
+// write the new text not loaded from the content, the lines are terminated
+// with the terminator of the last written line
+func (w *docWriter) Write(p []byte) (int, error) {
+	s := string(p)
+	if w.terminator() == "\r\n" {
+		s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	}
+	return len(p), w.write(s)
+}
+
+func (w *docWriter) writeLines(lines []string) error {
+	for _, line := range lines {
+		if err := w.write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write the loaded content with the original format, only the keys
+// changed are re-formatted
+func (ini *Ini) writeDocument(writer io.Writer) error {
+	w := &docWriter{writer: writer}
+	// the new keys of a section are written after the last node of the section
+	lastNodes := make(map[*Section]*docNode)
+	for _, node := range ini.doc {
+		if node.kind != triviaNode && node.alive(ini) {
+			lastNodes[node.section] = node
+		}
+	}
+	for _, node := range ini.doc {
+		if err := ini.writeNode(w, node); err != nil {
+			return err
+		}
+		if node.kind != triviaNode && lastNodes[node.section] == node {
+			if err := writeNewKeys(w, node.section); err != nil {
+				return err
+			}
+		}
+	}
+	for _, section := range ini.Sections() {
+		// the section loaded only from the included files is not written
+		if _, ok := lastNodes[section]; !ok && section.line <= 0 {
+			if err := section.Write(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ini *Ini) writeNode(w *docWriter, node *docNode) error {
+	switch node.kind {
+	case sectionNode:
+		if !node.alive(ini) {
+			return nil
+		}
+		section := node.section
+		if err := node.writeComment(w, section.comment); err != nil {
+			return err
+		}
+		if section.Name != node.sectionName || section.inlineComment != node.inlineComment {
+			header := fmt.Sprintf("[%s]", section.Name)
+			return w.write(appendInlineComment(header, section.inlineComment) + node.terminator())
+		}
+	case keyNode:
+		if !node.alive(ini) {
+			return nil
+		}
+		k, ok := node.section.keyValues[node.key].(*normalKey)
+		if !ok {
+			return nil
+		}
+		if node.multiValue {
+			return node.writeMultiValue(w, k)
+		}
+		if k.node != node {
+			// the key is overridden by a later one
+			if err := w.writeLines(node.leading); err != nil {
+				return err
+			}
+			return w.writeLines(node.lines)
+		}
+		if err := node.writeComment(w, k.comment); err != nil {
+			return err
+		}
+		if k.inlineComment != node.inlineComment || !equalStrings(k.rawValues(), node.values) {
+			return node.writeValues(w, k)
+		}
+	}
+	return w.writeLines(node.lines)
+}
+
+// write each value of the key in a line with the original format
+func (node *docNode) writeValues(w *docWriter, k *normalKey) error {
+	values := k.rawValues()
+	for i, value := range values {
+		line := node.prefix + toEscape(value)
+		if i == len(values)-1 {
+			if k.inlineComment != node.inlineComment {
+				line = appendInlineComment(line, k.inlineComment)
+			} else {
+				line = line + node.suffix
+			}
+		}
+		if err := w.write(line + node.terminator()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write the value of the node in place for a key with multiple values, the
+// unchanged value is written with the original lines and the values added
+// after loading are written after the last node of the key
+func (node *docNode) writeMultiValue(w *docWriter, k *normalKey) error {
+	values := k.rawValues()
+	i := len(node.values) - 1
+	if i >= len(values) {
+		// the value is removed
+		return nil
+	}
+	last := k.node == node
+	inlineComment := node.inlineComment
+	if last {
+		if err := node.writeComment(w, k.comment); err != nil {
+			return err
+		}
+		inlineComment = k.inlineComment
+	} else if err := w.writeLines(node.leading); err != nil {
+		return err
+	}
+	if values[i] == node.values[i] && inlineComment == node.inlineComment {
+		if err := w.writeLines(node.lines); err != nil {
+			return err
+		}
+	} else {
+		line := node.prefix + toEscape(values[i])
+		if inlineComment != node.inlineComment {
+			line = appendInlineComment(line, inlineComment)
+		} else {
+			line = line + node.suffix
+		}
+		if err := w.write(line + node.terminator()); err != nil {
+			return err
+		}
+	}
+	if !last {
+		return nil
+	}
+	for _, value := range values[i+1:] {
+		if err := w.write(node.prefix + toEscape(value) + node.terminator()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// write the comment lines before the section or key, the original lines
+// are written if the comment is not changed
+func (node *docNode) writeComment(w *docWriter, comment string) error {
+	if comment == node.comment {
+		return w.writeLines(node.leading)
+	}
+	return writeComment(w, comment, node.terminator())
+}
+
+// write the keys not loaded from the content
+func writeNewKeys(w *docWriter, section *Section) error {
+	for _, key := range section.rawKeys() {
+		if k, ok := key.(*normalKey); ok && k.node == nil {
+			if err := writeKey(w, k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package ini
+
+import (
+	"testing"
+)
+
+func TestPreserveFormatRoundTrip(t *testing.T) {
+	data := "# global comment\r\n\r\n[section1]\r\n  key1 : value1   ; comment\r\nkey2=\"\"\"multi\r\nline\"\"\"\r\nkey3 = a \\\r\n  b\r\ngarbage line\r\n\r\n; trailing\r\n[section2]\r\nkey4= value4"
+	ini := NewIni()
+	ini.SetPreserveFormat(true)
+	ini.LoadString(data)
+	if ini.String() != data {
+		t.Errorf("the content is changed:\n%q", ini.String())
+	}
+}
+
+func TestPreserveFormatEdit(t *testing.T) {
+	data := `; comment of section1
+[section1]
+  key1 : value1   ; comment of key1
+key2 = """multi
+line"""
+
+# comment of section2
+[section2]
+key3 = value3`
+	ini := NewIni()
+	ini.SetPreserveFormat(true)
+	ini.LoadString(data)
+	section1, _ := ini.GetSection("section1")
+	section1.Add("key1", "new value1")
+	section1.Add("key2", "single")
+	section1.Add("key5", "value5")
+	section2, _ := ini.GetSection("section2")
+	section2.Add("key4", "value4")
+	ini.NewSection("section3").Add("key6", "value6")
+
+	expect := `; comment of section1
+[section1]
+  key1 : new value1   ; comment of key1
+key2 = single
+key5=value5
+
+# comment of section2
+[section2]
+key3 = value3
+key4=value4
+[section3]
+key6=value6
+`
+	if ini.String() != expect {
+		t.Errorf("the content is not changed as expected:\n%s", ini.String())
+	}
+}
+
+func TestPreserveFormatEditCRLF(t *testing.T) {
+	data := "[s]\r\nx = 1\r\ny = 2\r\n\r\n[t]\r\nz = 3"
+	ini := NewIni()
+	ini.SetPreserveFormat(true)
+	ini.LoadString(data)
+	section, _ := ini.GetSection("s")
+	section.Add("k4", "added")
+	section.Key("k4").SetComment("new key")
+	ini.NewSection("u").Add("k5", "new")
+	expect := "[s]\r\nx = 1\r\ny = 2\r\n# new key\r\nk4=added\r\n\r\n[t]\r\nz = 3\r\n[u]\r\nk5=new\r\n"
+	if ini.String() != expect {
+		t.Errorf("the new lines should be terminated with CRLF:\n%q", ini.String())
+	}
+}
+
+func TestPreserveFormatEditComment(t *testing.T) {
+	data := "# comment1\n[section1]\n; comment of key1\nkey1 = value1 ; inline\nkey2 = value2\n"
+	ini := NewIni()
+	ini.SetPreserveFormat(true)
+	ini.LoadString(data)
+	section, _ := ini.GetSection("section1")
+	section.SetComment("")
+	section.Key("key1").SetInlineComment("new inline")
+	section.Key("key2").SetComment("comment of key2")
+	expect := "[section1]\n; comment of key1\nkey1 = value1 # new inline\n# comment of key2\nkey2 = value2\n"
+	if ini.String() != expect {
+		t.Errorf("the comments are not changed as expected:\n%s", ini.String())
+	}
+}
+
+func TestPreserveFormatMultiValues(t *testing.T) {
+	data := "[section1]\nserver = a\nport = 1\nserver = b ; comment\n"
+	ini := NewIni()
+	ini.SetPreserveFormat(true)
+	ini.SetMultiValueKeys(true)
+	ini.LoadString(data)
+	if ini.String() != data {
+		t.Errorf("the content is changed:\n%s", ini.String())
+	}
+	section, _ := ini.GetSection("section1")
+	section.AddValue("server", "c")
+	expect := "[section1]\nserver = a\nport = 1\nserver = b ; comment\nserver = c\n"
+	if ini.String() != expect {
+		t.Errorf("the values are not changed as expected:\n%s", ini.String())
+	}
+
+	data = "[s]\ns = a ; first\nt=1\ns = b\n"
+	ini = NewIni()
+	ini.SetPreserveFormat(true)
+	ini.SetMultiValueKeys(true)
+	ini.LoadString(data)
+	section, _ = ini.GetSection("s")
+	section.AddValue("s", "c")
+	if expect := data + "s = c\n"; ini.String() != expect {
+		t.Errorf("only the new value should be appended:\n%s", ini.String())
+	}
+	section.Add("s", "d")
+	if expect := "[s]\ns = d ; first\nt=1\n"; ini.String() != expect {
+		t.Errorf("the removed values should not be written:\n%q", ini.String())
+	}
+}
@@ -0,0 +1,90 @@
+package ini
+
+import (
+	"strings"
+)
+
+// EnvCase is how to convert the case of the section and key names to the
+// names of the environment variables
+type EnvCase int
+
+const (
+	// convert the names to upper case, e.g. DATABASE_HOST
+	EnvUpperCase EnvCase = iota
+	// convert the names to lower case, e.g. database_host
+	EnvLowerCase
+	// keep the case of the names
+	EnvKeepCase
+)
+
+// EnvOverride lets the environment variables override the keys loaded. The
+// name of the variable is the prefix, the section name and the key name joined
+// with the separator, e.g. MYAPP_DATABASE_HOST for the key host in the section
+// database. The section name is omitted for the keys in the default section
+type EnvOverride struct {
+	// the prefix of the names, e.g. "MYAPP". No prefix if it is empty
+	Prefix string
+	// the separator between the prefix, section and key, "_" if empty
+	Separator string
+	// how to convert the case of the section and key names
+	Case EnvCase
+	// normalize the section and key names. If it is nil, the chars other
+	// than letters, digits and '_' are replaced with '_', e.g. "log-file"
+	// and "log.file" are changed to "log_file"
+	Normalize func(name string) string
+	// looks up the variables, the environment is used if it is nil
+	Resolver Resolver
+}
+
+// get the name of the environment variable for the key in the section
+func (o *EnvOverride) envName(section string, key string, defaultSection string) string {
+	sep := o.Separator
+	if len(sep) <= 0 {
+		sep = "_"
+	}
+	names := make([]string, 0)
+	if len(o.Prefix) > 0 {
+		names = append(names, o.Prefix)
+	}
+	if section != defaultSection {
+		names = append(names, o.convert(section))
+	}
+	names = append(names, o.convert(key))
+	return strings.Join(names, sep)
+}
+
+func (o *EnvOverride) convert(name string) string {
+	if o.Normalize != nil {
+		name = o.Normalize(name)
+	} else {
+		name = normalizeEnvName(name)
+	}
+	switch o.Case {
+	case EnvUpperCase:
+		return strings.ToUpper(name)
+	case EnvLowerCase:
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// look up the value of the key in the section
+func (o *EnvOverride) lookup(section string, key string, defaultSection string) (string, string, bool) {
+	name := o.envName(section, key, defaultSection)
+	resolver := o.Resolver
+	if resolver == nil {
+		resolver = EnvResolver{}
+	}
+	value, ok := resolver.Lookup(name)
+	return name, value, ok
+}
+
+// replace the chars other than letters, digits and '_' with '_'
+func normalizeEnvName(name string) string {
+	return strings.Map(func(ch rune) rune {
+		if ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') {
+			return ch
+		}
+		return '_'
+	}, name)
+}
@@ -2,64 +2,217 @@ package ini
 
 import (
 	"bytes"
-	"os"
+	"fmt"
 	"strings"
 )
 
-func get_env_value(env string) (string, bool) {
-	pos := strings.Index(env, ":")
-	if pos == -1 {
-		return os.LookupEnv(env)
-	}
+// envExpander expands the environment variables in a value like the shell:
+//
+//	$VAR, ${VAR}         the value of VAR
+//	${VAR-default}       default if VAR is not set
+//	${VAR:-default}      default if VAR is not set or empty
+//	${VAR=default}       like ${VAR-default} and VAR is set to default
+//	${VAR:=default}      like ${VAR:-default} and VAR is set to default
+//	${VAR+alt}           alt if VAR is set, otherwise empty
+//	${VAR:+alt}          alt if VAR is set and not empty, otherwise empty
+//	${VAR?message}       error with message if VAR is not set
+//	${VAR:?message}      error with message if VAR is not set or empty
+//	$$                   a single $, it is kept as it is if keepUnknown is
+//	                     true and unescaped by the interpolation
+//
+// The default, alt and message can include other variables. The variables
+// set by ${VAR=default} are only visible in the same value, the environment
+// of the process is not changed
+type envExpander struct {
+	// looks up the value of the variables
+	resolver Resolver
+	// keep the variable not found as it is instead of removing it
+	keepUnknown bool
+	// the variables set by ${VAR=default} or ${VAR:=default}
+	vars map[string]string
+}
 
-	real_env := env[0:pos]
-	def_value := env[pos+1:]
-	if len(def_value) > 0 && def_value[0] == '-' {
-		def_value = def_value[1:]
-	}
-	if value, ok := os.LookupEnv(real_env); ok {
-		return value, ok
-	} else {
-		return def_value, true
+func newEnvExpander(resolver Resolver, keepUnknown bool) *envExpander {
+	return &envExpander{resolver: resolver, keepUnknown: keepUnknown, vars: make(map[string]string)}
+}
+
+func (e *envExpander) lookup(name string) (string, bool) {
+	if value, ok := e.vars[name]; ok {
+		return value, true
 	}
+	return e.resolver.Lookup(name)
 }
 
-func replace_env(s string) string {
+func (e *envExpander) expand(s string) (string, error) {
 	n := len(s)
-	env_start_pos := -1
 	result := bytes.NewBuffer(make([]byte, 0))
-
 	for i := 0; i < n; i++ {
-		//if env start flag "${" is found but env end flag "}" is not found
-		if env_start_pos >= 0 && s[i] != '}' {
-			continue
-		}
-		switch s[i] {
-		case '\\':
+		switch {
+		case s[i] == '\\':
 			result.WriteByte(s[i])
 			if i+1 < n {
 				i++
 				result.WriteByte(s[i])
 			}
-		case '$':
-			if i+1 < n && s[i+1] == '{' {
-				env_start_pos = i
-				i++
-			} else {
-				result.WriteByte(s[i])
+		case s[i] != '$' || i+1 >= n:
+			result.WriteByte(s[i])
+		case s[i+1] == '$':
+			// kept for the interpolation to unescape it
+			if e.keepUnknown {
+				result.WriteByte('$')
 			}
-		case '}':
-			if env_start_pos >= 0 {
-				if env_value, ok := get_env_value(s[env_start_pos+2 : i]); ok {
-					result.WriteString(env_value)
-				}
-				env_start_pos = -1
-			} else {
-				result.WriteByte(s[i])
+			result.WriteByte('$')
+			i++
+		case s[i+1] == '{':
+			end := matchBrace(s, i+2)
+			if end == -1 {
+				//env end flag "}" is not found
+				return result.String(), nil
+			}
+			value, err := e.expandBraces(s[i : end+1])
+			if err != nil {
+				return "", err
 			}
+			result.WriteString(value)
+			i = end
+		case isEnvNameStart(s[i+1]):
+			end := i + 2
+			for end < n && isEnvNameChar(s[end]) {
+				end++
+			}
+			if value, ok := e.lookup(s[i+1 : end]); ok {
+				result.WriteString(value)
+			} else if e.keepUnknown {
+				result.WriteString(s[i:end])
+			}
+			i = end - 1
 		default:
 			result.WriteByte(s[i])
 		}
 	}
-	return result.String()
+	return result.String(), nil
+}
+
+// expand the variable in the form of ${...}
+func (e *envExpander) expandBraces(s string) (string, error) {
+	expr := s[2 : len(s)-1]
+	pos := strings.IndexAny(expr, ":-=+?")
+	if pos == -1 || !isEnvName(expr[0:pos]) {
+		if value, ok := e.lookup(expr); ok {
+			return value, nil
+		} else if e.keepUnknown {
+			return s, nil
+		}
+		return "", nil
+	}
+	name := expr[0:pos]
+	op := expr[pos:]
+	// the colon variants take an empty variable as not set
+	colon := op[0] == ':'
+	if colon {
+		op = op[1:]
+	}
+	if len(op) <= 0 || !strings.ContainsRune("-=+?", rune(op[0])) {
+		// not a supported expansion, keep it as it is
+		return s, nil
+	}
+	word := op[1:]
+	value, ok := e.lookup(name)
+	if colon && len(value) <= 0 {
+		ok = false
+	}
+	switch op[0] {
+	case '-':
+		if ok {
+			return value, nil
+		}
+		return e.expand(word)
+	case '=':
+		if ok {
+			return value, nil
+		}
+		value, err := e.expand(word)
+		if err == nil {
+			e.vars[name] = value
+		}
+		return value, err
+	case '+':
+		if ok {
+			return e.expand(word)
+		}
+		return "", nil
+	default:
+		if ok {
+			return value, nil
+		}
+		msg, err := e.expand(word)
+		if err != nil {
+			return "", err
+		}
+		if len(msg) <= 0 {
+			msg = "parameter null or not set"
+		}
+		return "", fmt.Errorf("%s: %s", name, msg)
+	}
+}
+
+// find the "}" matching the "${" before the start, return -1 if not found
+func matchBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func isEnvName(name string) bool {
+	if len(name) <= 0 || !isEnvNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isEnvNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isEnvNameStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isEnvNameChar(ch byte) bool {
+	return isEnvNameStart(ch) || (ch >= '0' && ch <= '9')
+}
+
+// replace the environment variables in s, the variable not found is removed
+// and s is returned as it is if it can't be expanded
+func replace_env(s string) string {
+	value, err := expand_env(s, EnvResolver{}, false)
+	if err != nil {
+		return s
+	}
+	return value
+}
+
+// replace the variables in s with the values found by the resolver, the
+// variable not found is removed or kept as it is if keepUnknown is true.
+// Return error if a variable required by ${VAR?message} is not set
+func expand_env(s string, resolver Resolver, keepUnknown bool) (string, error) {
+	value, err := newEnvExpander(resolver, keepUnknown).expand(s)
+	if err != nil {
+		return s, err
+	}
+	return value, nil
 }
@@ -34,3 +34,137 @@ func TestStringWithEscapedBeforeEnv(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestStringWithEnvWithoutBraces(t *testing.T) {
+	os.Setenv("INI_TEST_ENV", "value")
+	defer os.Unsetenv("INI_TEST_ENV")
+	if v := replace_env("a $INI_TEST_ENV/b $5 $"); v != "a value/b $5 $" {
+		t.Errorf("fail to replace env without braces: %s", v)
+	}
+	if v := replace_env("cost $$INI_TEST_ENV"); v != "cost $INI_TEST_ENV" {
+		t.Errorf("fail to escape $$: %s", v)
+	}
+}
+
+func TestStringWithEnvUnsetOrEmpty(t *testing.T) {
+	os.Setenv("INI_TEST_EMPTY_ENV", "")
+	defer os.Unsetenv("INI_TEST_EMPTY_ENV")
+	tests := map[string]string{
+		"${INI_TEST_EMPTY_ENV-default}":     "",
+		"${INI_TEST_EMPTY_ENV:-default}":    "default",
+		"${INI_TEST_NOT_EXIST_ENV-default}": "default",
+		"${INI_TEST_EMPTY_ENV+alt}":         "alt",
+		"${INI_TEST_EMPTY_ENV:+alt}":        "",
+		"${INI_TEST_NOT_EXIST_ENV+alt}":     "",
+		"${INI_TEST_EMPTY_ENV:foo}":         "${INI_TEST_EMPTY_ENV:foo}",
+	}
+	for s, expect := range tests {
+		if v := replace_env(s); v != expect {
+			t.Errorf("%s should be replaced with %q but it is %q", s, expect, v)
+		}
+	}
+}
+
+func TestStringWithEnvAssignDefault(t *testing.T) {
+	s := "${INI_TEST_NOT_EXIST_ENV:=a}-${INI_TEST_NOT_EXIST_ENV}"
+	if v := replace_env(s); v != "a-a" {
+		t.Errorf("fail to assign the default value: %s", v)
+	}
+	if _, ok := os.LookupEnv("INI_TEST_NOT_EXIST_ENV"); ok {
+		t.Error("the environment should not be changed")
+	}
+}
+
+func TestStringWithNestedEnv(t *testing.T) {
+	os.Setenv("INI_TEST_ENV", "value")
+	defer os.Unsetenv("INI_TEST_ENV")
+	s := "${INI_TEST_NOT_EXIST_ENV:-${INI_TEST_ENV}/{x}}"
+	if v := replace_env(s); v != "value/{x}" {
+		t.Errorf("fail to replace the nested env: %s", v)
+	}
+}
+
+func TestStringWithRequiredEnv(t *testing.T) {
+	_, err := expand_env("${INI_TEST_NOT_EXIST_ENV:?must be set}", EnvResolver{}, false)
+	if err == nil || err.Error() != "INI_TEST_NOT_EXIST_ENV: must be set" {
+		t.Errorf("the required env should be reported: %v", err)
+	}
+	ini := NewIni()
+	err = ini.LoadStringE("[a]\nb = ${INI_TEST_NOT_EXIST_ENV?}\n")
+	if pe, ok := err.(*ParseError); !ok || pe.Line != 2 || pe.Err == nil {
+		t.Errorf("the required env should be reported when loading: %v", err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	resolver := ChainResolver{MapResolver{"HOST": "db"}, MapResolver{"HOST": "x", "PORT": "5432"}}
+	v, err := expand_env("${HOST}:${PORT}/${HOME}", resolver, false)
+	if err != nil || v != "db:5432/" {
+		t.Errorf("fail to resolve with the chained resolvers: %s, %v", v, err)
+	}
+
+	data := "[db]\nurl = ${HOST}:${PORT:-5432}\n"
+	ini := NewIni()
+	ini.SetResolver(MapResolver{"HOST": "db"})
+	ini.LoadString(data)
+	if v, _ := ini.GetValue("db", "url"); v != "db:5432" {
+		t.Errorf("fail to resolve with the resolver of ini: %s", v)
+	}
+
+	ini = NewIni()
+	ini.SetResolver(NoopResolver{})
+	ini.LoadString(data)
+	if v, _ := ini.GetValue("db", "url"); v != "${HOST}:${PORT:-5432}" {
+		t.Errorf("the variables should not be expanded: %s", v)
+	}
+}
+
+func TestRawValue(t *testing.T) {
+	data := "[db]\nurl = ${INI_TEST_HOST}:5432\nuser = ${INI_TEST_USER:?}\n"
+	resolver := MapResolver{"INI_TEST_HOST": "db", "INI_TEST_USER": "test"}
+
+	ini := NewIni()
+	ini.SetResolver(resolver)
+	ini.LoadString(data)
+	section, _ := ini.GetSection("db")
+	key := section.Key("url")
+	if v, _ := key.Value(); v != "db:5432" || key.Raw() != "${INI_TEST_HOST}:5432" {
+		t.Errorf("wrong value %s or raw value %s", v, key.Raw())
+	}
+	resolver["INI_TEST_HOST"] = "localhost"
+	if v, _ := key.Value(); v != "db:5432" {
+		t.Errorf("the value should be expanded when loading in eager mode: %s", v)
+	}
+	if ini.String() != "[db]\nurl=${INI_TEST_HOST}\\:5432\nuser=${INI_TEST_USER\\:?}\n" {
+		t.Errorf("the raw values should be written:\n%s", ini.String())
+	}
+
+	ini = NewIni()
+	ini.SetResolver(resolver)
+	ini.SetLazyExpansion(true)
+	if err := ini.LoadStringE(data); err != nil {
+		t.Errorf("fail to load in lazy mode: %v", err)
+	}
+	section, _ = ini.GetSection("db")
+	key = section.Key("url")
+	if v, _ := key.Value(); v != "localhost:5432" {
+		t.Errorf("the value should be expanded when read in lazy mode: %s", v)
+	}
+	resolver["INI_TEST_HOST"] = "db"
+	if v, _ := key.Value(); v != "db:5432" {
+		t.Errorf("the value should be expanded when read in lazy mode: %s", v)
+	}
+	delete(resolver, "INI_TEST_USER")
+	if _, err := ini.GetValue("db", "user"); err == nil {
+		t.Error("the required variable should be reported when read")
+	}
+	ini = NewIni()
+	ini.SetResolver(resolver)
+	ini.Load(data)
+	if _, err := ini.GetValue("db", "user"); err == nil {
+		t.Error("the required variable should be reported by the getter in eager mode")
+	}
+	if _, err := ini.GetInt("db", "user"); err == nil {
+		t.Error("the required variable should be reported by the typed getter in eager mode")
+	}
+}
@@ -0,0 +1,65 @@
+package ini
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError describes a problem found at a given position of the .ini
+// content while loading it
+type ParseError struct {
+	// name of the source, e.g. the file name, may be empty
+	Source string
+	// line number, starts from 1
+	Line int
+	// column number, starts from 1
+	Column int
+	// the text of the offending line
+	Text string
+	// description of the problem
+	Msg string
+	// the underlying error if the problem is caused by another error
+	Err error
+	// the positions of the include directives by which the source is
+	// included, the innermost first. Empty if it is not included
+	Includes []string
+}
+
+func newParseError(source string, line int, column int, text string, msg string) *ParseError {
+	return &ParseError{Source: source, Line: line, Column: column, Text: text, Msg: msg}
+}
+
+func (e *ParseError) Error() string {
+	pos := fmt.Sprintf("%d:%d", e.Line, e.Column)
+	if len(e.Source) > 0 {
+		pos = fmt.Sprintf("%s:%s", e.Source, pos)
+	}
+	msg := e.Msg
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	if len(e.Includes) > 0 {
+		pos = fmt.Sprintf("%s (included from %s)", pos, strings.Join(e.Includes, ", "))
+	}
+	if len(e.Text) > 0 {
+		return fmt.Sprintf("%s: %s: %q", pos, msg, e.Text)
+	}
+	return fmt.Sprintf("%s: %s", pos, msg)
+}
+
+// return the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors collects all the problems found in the content loaded in
+// strict mode
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, 0)
+	for _, pe := range e {
+		msgs = append(msgs, pe.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,96 @@
+package ini
+
+import (
+	"flag"
+	"strings"
+)
+
+// keyFlag is the flag.Value of a key, the value set by the command line
+// overrides the value of the key
+type keyFlag struct {
+	section *Section
+	key     string
+	name    string
+}
+
+func (f *keyFlag) String() string {
+	if f.section == nil {
+		return ""
+	}
+	value, _ := f.section.GetValue(f.key)
+	return value
+}
+
+// the value is a literal, the variables and references in it are not expanded
+func (f *keyFlag) Set(value string) error {
+	f.section.Add(f.key, value)
+	k := f.section.keyValues[f.key].(*normalKey)
+	k.expanded = []string{value}
+	k.expandErrs = nil
+	k.source = "-" + f.name
+	k.fromFlag = true
+	k.literal = true
+	return nil
+}
+
+// boolKeyFlag is the flag of a key with a bool value, it can be set by
+// "-name" without the value
+type boolKeyFlag struct {
+	keyFlag
+}
+
+func (f *boolKeyFlag) IsBoolFlag() bool {
+	return true
+}
+
+// define a flag for each key of all the sections in the flag set, see
+// Section.BindFlags() for the flags defined
+func (ini *Ini) BindFlags(flagSet *flag.FlagSet) {
+	for _, section := range ini.Sections() {
+		section.BindFlags(flagSet)
+	}
+}
+
+// define a flag for each key of the section in the flag set. The flag name
+// is "section.key", or the key name for the keys in the default section. The
+// default value of the flag is the value of the key and the usage is its
+// comment. The values of the flags set by the command line override the keys
+// when the flag set is parsed as literals without expanding the variables and
+// references, and they are not overridden by the environment variables. A
+// flag already defined in the flag set is skipped, so a flag defined by hand
+// takes precedence
+func (section *Section) BindFlags(flagSet *flag.FlagSet) {
+	for _, name := range section.keyNames {
+		k, ok := section.keyValues[name].(*normalKey)
+		if !ok {
+			continue
+		}
+		f := keyFlag{section: section, key: name, name: section.flagName(name)}
+		if flagSet.Lookup(f.name) != nil {
+			continue
+		}
+		usage := strings.ReplaceAll(k.comment, "\n", " ")
+		if isBoolValue(f.String()) {
+			flagSet.Var(&boolKeyFlag{f}, f.name, usage)
+		} else {
+			flagSet.Var(&f, f.name, usage)
+		}
+	}
+}
+
+// get the flag name of the key
+func (section *Section) flagName(key string) string {
+	if section.ini != nil && section.Name == section.ini.defaultSectionName {
+		return key
+	}
+	return section.Name + "." + key
+}
+
+// check if the value is "true" or "false" accepted by the bool flags
+func isBoolValue(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package ini
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// osFS reads the files from the disk with the paths of the os, it is the
+// file system of the files not loaded by LoadFS()
+type osFS struct {
+}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+// return true if the name is in the os paths instead of the slash separated
+// paths of fs.FS
+func isOSFS(fsys fs.FS) bool {
+	_, ok := fsys.(osFS)
+	return ok
+}
+
+// return the directory of the file in the file system
+func fsDir(fsys fs.FS, name string) string {
+	if isOSFS(fsys) {
+		return filepath.Dir(name)
+	}
+	return path.Dir(name)
+}
+
+// resolve the name relative to the directory in the file system, the name
+// starts with "/" is relative to the root of fs.FS
+func fsJoin(fsys fs.FS, dir string, name string) string {
+	if isOSFS(fsys) {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	if strings.HasPrefix(name, "/") {
+		return path.Clean(name[1:])
+	}
+	return path.Join(dir, name)
+}
+
+// get the identity of the file in the file system to detect the include cycle
+func fsAbs(fsys fs.FS, name string) (string, error) {
+	if isOSFS(fsys) {
+		return filepath.Abs(name)
+	}
+	return path.Clean(name), nil
+}
+
+// return the files matched by the glob pattern in lexical order, the pattern
+// without meta chars must match an existing file
+func fsGlob(fsys fs.FS, pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		if _, err := fs.Stat(fsys, pattern); err != nil {
+			return nil, err
+		}
+		return []string{pattern}, nil
+	}
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Load the files matched by the glob patterns from the file system, e.g.
+// embed.FS or fstest.MapFS. The files matched by one pattern are loaded in
+// lexical order, and the include directives are resolved in the file system
+func (ini *Ini) LoadFS(fsys fs.FS, patterns ...string) {
+	ini.LoadFSE(fsys, patterns...)
+}
+
+// Same as LoadFS() but return the error if a file can't be read or parsed
+// or a pattern without glob meta chars does not match a file
+func (ini *Ini) LoadFSE(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		ini.watchFile(fsys, pattern)
+		files, err := fsGlob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := ini.loadFile(fsys, file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Load all the files matched by the glob pattern in the directory in lexical
+// order, e.g. the drop-in files "/etc/app/conf.d/*.ini". The pattern is "*.ini"
+// if it is empty. The sections and keys defined in more than one file are
+// merged by the duplicate section policy and duplicate key policy, so the
+// later files override the keys of the previous files by default
+func (ini *Ini) LoadDir(dir string, pattern string) {
+	ini.LoadDirE(dir, pattern)
+}
+
+// Same as LoadDir() but return the error if the directory does not exist
+// or a file can't be read or parsed
+func (ini *Ini) LoadDirE(dir string, pattern string) error {
+	return ini.LoadDirFSE(osFS{}, dir, pattern)
+}
+
+// Same as LoadDir() but load the files from the directory in the file
+// system, e.g. embed.FS or fstest.MapFS
+func (ini *Ini) LoadDirFS(fsys fs.FS, dir string, pattern string) {
+	ini.LoadDirFSE(fsys, dir, pattern)
+}
+
+// Same as LoadDirFS() but return the error if the directory does not exist
+// or a file can't be read or parsed
+func (ini *Ini) LoadDirFSE(fsys fs.FS, dir string, pattern string) error {
+	if len(pattern) <= 0 {
+		pattern = includeDirPattern
+	}
+	if _, err := fs.Stat(fsys, dir); err != nil {
+		return err
+	}
+	return ini.LoadFSE(fsys, fsJoin(fsys, dir, pattern))
+}
+
+// load the .ini file from the file system
+func (ini *Ini) loadFile(fsys fs.FS, name string) error {
+	ini.watchFile(fsys, name)
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		return fmt.Errorf("%s is a directory", name)
+	}
+	l := newLoader(ini, f, name)
+	l.fsys = fsys
+	if l.path, err = fsAbs(fsys, name); err != nil {
+		return err
+	}
+	return l.load()
+}
@@ -0,0 +1,118 @@
+package ini
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// the pattern of the files included by !includedir
+const includeDirPattern = "*.ini"
+
+// parse the include directive "!include path" or "!includedir dir"
+//
+// return the path, true if it is "!includedir" and true if it is a directive
+func parseIncludeDirective(line string) (string, bool, bool) {
+	line = strings.TrimSpace(line)
+	for _, directive := range []string{"!includedir", "!include"} {
+		if strings.HasPrefix(line, directive) {
+			path := line[len(directive):]
+			if len(path) > 0 && (path[0] == ' ' || path[0] == '\t') {
+				return strings.TrimSpace(path), directive == "!includedir", true
+			}
+		}
+	}
+	return "", false, false
+}
+
+// include the files matched by the path, or the files in the directory if
+// dir is true, at the line of the including content
+func (l *loader) include(name string, dir bool, lineNo int, line string) {
+	name, err := l.ini.expandEnv(name)
+	if err != nil {
+		l.addIncludeError(lineNo, line, "fail to expand the include path", err)
+		return
+	}
+	if len(name) <= 0 {
+		l.addError(lineNo, getIndent(line)+1, line, "empty include path")
+		return
+	}
+	name = fsJoin(l.fsys, l.dir(), name)
+	if dir {
+		if _, err := fs.Stat(l.fsys, name); err != nil {
+			l.addIncludeError(lineNo, line, "fail to include directory "+name, err)
+			return
+		}
+		name = fsJoin(l.fsys, name, includeDirPattern)
+	}
+	l.ini.watchFile(l.fsys, name)
+	files, err := fsGlob(l.fsys, name)
+	if err != nil {
+		l.addIncludeError(lineNo, line, "fail to include "+name, err)
+		return
+	}
+	for _, file := range files {
+		l.includeFile(file, lineNo, line)
+	}
+}
+
+// load the included file with the current section of the including content
+func (l *loader) includeFile(file string, lineNo int, line string) {
+	abs, err := fsAbs(l.fsys, file)
+	if err != nil {
+		l.addIncludeError(lineNo, line, "fail to include "+file, err)
+		return
+	}
+	files := []string{file}
+	for p := l; p != nil; p = p.parent {
+		if len(p.path) <= 0 {
+			continue
+		}
+		files = append([]string{p.source}, files...)
+		if p.path == abs {
+			l.addError(lineNo, getIndent(line)+1, line, "include cycle: "+strings.Join(files, " -> "))
+			return
+		}
+	}
+	f, err := l.fsys.Open(file)
+	if err != nil {
+		l.addIncludeError(lineNo, line, "fail to include "+file, err)
+		return
+	}
+	defer f.Close()
+	sub := newLoader(l.ini, f, file)
+	sub.fsys = l.fsys
+	sub.path = abs
+	sub.parent = l
+	sub.includeLine = lineNo
+	sub.curSection = l.curSection
+	sub.ignoreSection = l.ignoreSection
+	// all the problems of the included file are kept by the errs
+	sub.load()
+	l.errs = append(l.errs, sub.errs...)
+	l.curSection = sub.curSection
+	l.ignoreSection = sub.ignoreSection
+}
+
+// record the problem of the include directive caused by err
+func (l *loader) addIncludeError(lineNo int, line string, msg string, err error) {
+	l.addError(lineNo, getIndent(line)+1, line, msg)
+	l.errs[len(l.errs)-1].Err = err
+}
+
+// get the directory of the relative include paths
+func (l *loader) dir() string {
+	if len(l.path) > 0 {
+		return fsDir(l.fsys, l.path)
+	}
+	return "."
+}
+
+// get the positions of the include directives by which the content is
+// included, the innermost first
+func (l *loader) includeChain() []string {
+	var chain []string
+	for p := l; p.parent != nil; p = p.parent {
+		chain = append(chain, definedAt(p.parent.source, p.includeLine))
+	}
+	return chain
+}
@@ -12,11 +12,56 @@ import (
 type Ini struct {
 	defaultSectionName string
 	sections           map[string]*Section
+	// section names in the order they are added
+	sectionNames []string
+	// report all the malformed content when loading if it is true
+	strict bool
+	// keep the original text of the loaded content if it is true
+	preserveFormat bool
+	// how to handle the section or key defined more than once when loading
+	sectionPolicy DuplicatePolicy
+	keyPolicy     DuplicatePolicy
+	// expands the references to other keys in the values, nil if disabled
+	interpolation Interpolation
+	// looks up the variables in the values, the environment is used if nil
+	resolver Resolver
+	// the variables are expanded when the values are read if it is true,
+	// otherwise when the values are added
+	lazyExpansion bool
+	// the include directives are processed if it is true
+	includeFiles bool
+	// the environment variables override the keys if it is not nil
+	envOverride *EnvOverride
+	// the original text of the loaded content in preserve format mode
+	doc []*docNode
+	// the files and glob patterns loaded, including the missing ones. They
+	// are recorded only if watchFiles is true
+	files      []watchedFile
+	watchFiles bool
 }
 
+// DuplicatePolicy decides how to handle a section or key defined more than once
+// when loading
+type DuplicatePolicy int
+
+const (
+	// the content of the sections with same name are merged, or all the values
+	// of the key are kept as multiple values
+	DuplicateMerge DuplicatePolicy = iota
+	// the later one replaces the previous one
+	DuplicateLastWins
+	// the later one is ignored
+	DuplicateFirstWins
+	// the later one is reported as error with the positions of both
+	// and it is ignored
+	DuplicateError
+)
+
 func NewIni() *Ini {
 	return &Ini{defaultSectionName: "default",
-		sections: make(map[string]*Section)}
+		sections:      make(map[string]*Section),
+		sectionPolicy: DuplicateMerge,
+		keyPolicy:     DuplicateLastWins}
 }
 
 func (ini *Ini) GetDefaultSectionName() string {
@@ -27,6 +72,188 @@ func (ini *Ini) SetDefaultSectionName(defSectionName string) {
 	ini.defaultSectionName = defSectionName
 }
 
+// enable or disable the strict mode. In strict mode, following content is
+// reported as error when loading, and all the errors found are returned
+// by the LoadXXXE() methods as ParseErrors:
+//  - line without key/value separator
+//  - section header without the end ']'
+//  - multi-line value without the end """
+//  - continuation char '\' at the end of the content
+//  - empty key name
+//
+// By default the strict mode is disabled, the malformed content is
+// skipped and only the first line without key/value separator is reported
+func (ini *Ini) SetStrict(strict bool) {
+	ini.strict = strict
+}
+
+// return true if the strict mode is enabled
+func (ini *Ini) IsStrict() bool {
+	return ini.strict
+}
+
+// enable or disable the preserve format mode, it must be enabled before
+// loading. In preserve format mode, the comments, empty lines, spaces and
+// the layout of the values in the loaded content are kept, and Write()
+// only changes the lines of the keys whose values are changed. The new
+// keys are written after the last key of their section and the new
+// sections are written at the end.
+func (ini *Ini) SetPreserveFormat(preserveFormat bool) {
+	ini.preserveFormat = preserveFormat
+}
+
+// return true if the preserve format mode is enabled
+func (ini *Ini) IsPreserveFormat() bool {
+	return ini.preserveFormat
+}
+
+// enable or disable the multi-value mode, it must be enabled before loading.
+// In multi-value mode, the values of a key repeated in a section are all kept
+// in the order they are loaded and they can be got by Key.Values(), otherwise
+// the later value overwrites the previous one. For example:
+//
+//  [program:test]
+//  server = a
+//  server = b
+//
+// It is same as setting the duplicate key policy to DuplicateMerge if it
+// is enabled or DuplicateLastWins if it is disabled
+func (ini *Ini) SetMultiValueKeys(multiValueKeys bool) {
+	if multiValueKeys {
+		ini.keyPolicy = DuplicateMerge
+	} else {
+		ini.keyPolicy = DuplicateLastWins
+	}
+}
+
+// return true if the multi-value mode is enabled
+func (ini *Ini) IsMultiValueKeys() bool {
+	return ini.keyPolicy == DuplicateMerge
+}
+
+// set how to handle the section defined more than once when loading, it is
+// DuplicateMerge by default
+func (ini *Ini) SetDuplicateSectionPolicy(policy DuplicatePolicy) {
+	ini.sectionPolicy = policy
+}
+
+// get the policy to handle the section defined more than once
+func (ini *Ini) GetDuplicateSectionPolicy() DuplicatePolicy {
+	return ini.sectionPolicy
+}
+
+// set how to handle the key defined more than once in a section when loading,
+// it is DuplicateLastWins by default
+func (ini *Ini) SetDuplicateKeyPolicy(policy DuplicatePolicy) {
+	ini.keyPolicy = policy
+}
+
+// get the policy to handle the key defined more than once in a section
+func (ini *Ini) GetDuplicateKeyPolicy() DuplicatePolicy {
+	return ini.keyPolicy
+}
+
+// set the interpolation to expand the references to other keys when the
+// value of a key is read, nil disables it. It should be set before loading
+// so the references are not removed as unknown environment variables
+func (ini *Ini) SetInterpolation(interpolation Interpolation) {
+	ini.interpolation = interpolation
+}
+
+// get the interpolation of the ini, nil if it is disabled
+func (ini *Ini) GetInterpolation() Interpolation {
+	return ini.interpolation
+}
+
+// set the resolver to look up the variables in the values, e.g. ${HOME}.
+// The environment is used by default, and NoopResolver disables the
+// expansion of the variables, e.g. for the untrusted files. It should be
+// set before loading
+func (ini *Ini) SetResolver(resolver Resolver) {
+	ini.resolver = resolver
+}
+
+// set if the variables in the values are expanded when the values are read
+// (lazy) or added (eager). It is eager by default, and the values added after
+// it is changed are expanded in the new mode. The raw values are always kept
+// and written back without expanding
+func (ini *Ini) SetLazyExpansion(lazy bool) {
+	ini.lazyExpansion = lazy
+}
+
+// return true if the variables are expanded when the values are read
+func (ini *Ini) IsLazyExpansion() bool {
+	return ini.lazyExpansion
+}
+
+// enable or disable the include directives when loading, it is disabled by
+// default. If it is enabled, the following lines include other files at the
+// position of the line, and the relative paths are relative to the directory
+// of the including file:
+//
+//     !include path.ini        the file or the files matched by a glob pattern
+//     !includedir conf.d/      the *.ini files in the directory
+//     include = path.ini       same as !include
+//
+// The files are included in lexical order
+func (ini *Ini) SetIncludeFiles(includeFiles bool) {
+	ini.includeFiles = includeFiles
+}
+
+// return true if the include directives are processed when loading
+func (ini *Ini) IsIncludeFiles() bool {
+	return ini.includeFiles
+}
+
+// let the environment variables override the keys when the keys are read,
+// e.g. MYAPP_DATABASE_HOST overrides the key host in the section database
+// with the prefix "MYAPP". Only the keys in the ini can be overridden, and
+// the values written are not changed. nil disables it
+func (ini *Ini) SetEnvOverride(override *EnvOverride) {
+	ini.envOverride = override
+}
+
+// get the environment override, nil if it is disabled
+func (ini *Ini) GetEnvOverride() *EnvOverride {
+	return ini.envOverride
+}
+
+// get the resolver to look up the variables in the values
+func (ini *Ini) GetResolver() Resolver {
+	if ini.resolver == nil {
+		return EnvResolver{}
+	}
+	return ini.resolver
+}
+
+// replace the variables in the value, the references to other keys are
+// kept if the interpolation is enabled. The ini may be nil
+func (ini *Ini) expandEnv(value string) (string, error) {
+	if ini == nil {
+		return expand_env(value, EnvResolver{}, false)
+	}
+	if _, ok := ini.resolver.(NoopResolver); ok {
+		return value, nil
+	}
+	if ini.interpolation == nil {
+		return expand_env(value, ini.GetResolver(), false)
+	}
+	if _, ok := ini.interpolation.(pythonDialect); ok {
+		return value, nil
+	}
+	return expand_env(value, ini.GetResolver(), true)
+}
+
+// create an empty Ini with the same settings
+func (ini *Ini) emptyCopy() *Ini {
+	c := *ini
+	c.sections = make(map[string]*Section)
+	c.sectionNames = nil
+	c.doc = nil
+	c.files = nil
+	return &c
+}
+
 // create a new section if the section with name does not exist
 // or return the exist one if the section with name already exists
 //
@@ -35,23 +262,29 @@ func (ini *Ini) NewSection(name string) *Section {
 		return section
 	}
 	section := NewSection(name)
-	ini.sections[name] = section
+	ini.AddSection(section)
 	return section
 }
 
 // add a section to the .ini file and overwrite the exist section
 // with same name
+//
+// the overwritten section keeps its position in the .ini file
 func (ini *Ini) AddSection(section *Section) {
+	if _, ok := ini.sections[section.Name]; !ok {
+		ini.sectionNames = append(ini.sectionNames, section.Name)
+	}
 	ini.sections[section.Name] = section
+	section.ini = ini
 }
 
 // Get all the section name in the ini
 //
-// return all the section names
+// return all the sections in the order they are added or loaded
 func (ini *Ini) Sections() []*Section {
 	r := make([]*Section, 0)
-	for _, section := range ini.sections {
-		r = append(r, section)
+	for _, name := range ini.sectionNames {
+		r = append(r, ini.sections[name])
 	}
 	return r
 }
@@ -226,6 +459,55 @@ func (ini *Ini) GetFloat64WithDefault(sectionName, key string, defValue float64)
 	return defValue
 }
 
+// get the value of key in the section as a list splitted by the delimiter sep,
+// see Key.Strings() for the format of the list
+func (ini *Ini) GetStrings(sectionName, key string, sep string) []string {
+	if section, ok := ini.sections[sectionName]; ok {
+		return section.GetStrings(key, sep)
+	}
+	return nil
+}
+
+// get the value of key in the section as a list of int
+func (ini *Ini) GetInts(sectionName, key string, sep string) ([]int, error) {
+	if section, ok := ini.sections[sectionName]; ok {
+		return section.GetInts(key, sep)
+	}
+	return nil, noSuchSection(sectionName)
+}
+
+// get the value of key in the section as a list of int64
+func (ini *Ini) GetInt64s(sectionName, key string, sep string) ([]int64, error) {
+	if section, ok := ini.sections[sectionName]; ok {
+		return section.GetInt64s(key, sep)
+	}
+	return nil, noSuchSection(sectionName)
+}
+
+// get the value of key in the section as a list of uint64
+func (ini *Ini) GetUint64s(sectionName, key string, sep string) ([]uint64, error) {
+	if section, ok := ini.sections[sectionName]; ok {
+		return section.GetUint64s(key, sep)
+	}
+	return nil, noSuchSection(sectionName)
+}
+
+// get the value of key in the section as a list of float64
+func (ini *Ini) GetFloat64s(sectionName, key string, sep string) ([]float64, error) {
+	if section, ok := ini.sections[sectionName]; ok {
+		return section.GetFloat64s(key, sep)
+	}
+	return nil, noSuchSection(sectionName)
+}
+
+// get the value of key in the section as a list of bool
+func (ini *Ini) GetBools(sectionName, key string, sep string) ([]bool, error) {
+	if section, ok := ini.sections[sectionName]; ok {
+		return section.GetBools(key, sep)
+	}
+	return nil, noSuchSection(sectionName)
+}
+
 func noSuchSection(sectionName string) error {
 	return fmt.Errorf("no such section:%s", sectionName)
 }
@@ -236,7 +518,8 @@ func (ini *Ini) String() string {
 	return buf.String()
 }
 
-// write the content of the .ini in the .ini file format, e.g. in following format:
+// write the content of the .ini in the .ini file format in the order the sections
+// and keys are added or loaded, e.g. in following format:
 //
 //  [section1]
 //  key1 = value1
@@ -245,7 +528,10 @@ func (ini *Ini) String() string {
 //  key3 = value3
 //  key4 = value4
 func (ini *Ini) Write(writer io.Writer) error {
-	for _, section := range ini.sections {
+	if ini.preserveFormat {
+		return ini.writeDocument(writer)
+	}
+	for _, section := range ini.Sections() {
 		err := section.Write(writer)
 		if err != nil {
 			return err
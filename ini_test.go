@@ -1,9 +1,17 @@
 package ini
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestSection(t *testing.T) {
@@ -161,3 +169,594 @@ key2 = value2
 		t.Error("Fail to load ini with multi line keys")
 	}
 }
+
+func TestLoadEWithInvalidLine(t *testing.T) {
+	data := "[section1]\nkey1=value1\nthis is not a key value\nkey2=value2"
+	ini, err := LoadE(data)
+	if ini != nil || err == nil {
+		t.Fatal("the invalid line is not reported")
+	}
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Line != 3 || pe.Column != 1 || pe.Text != "this is not a key value" {
+		t.Errorf("wrong parse error: %v", err)
+	}
+
+	//the lenient Load still loads the other lines
+	ini = Load(data)
+	if ini.GetValueWithDefault("section1", "key2", "") != "value2" {
+		t.Error("Fail to load the lines after the invalid line")
+	}
+}
+
+func TestParseFileNotExist(t *testing.T) {
+	if _, err := ParseFile("./not-exist-file.ini"); !os.IsNotExist(err) {
+		t.Errorf("missing file is not reported: %v", err)
+	}
+	ini := NewIni()
+	if err := ini.LoadFileE("./not-exist-file.ini"); err == nil {
+		t.Error("missing file is not reported")
+	}
+}
+
+func TestLoadReaderEWithReadError(t *testing.T) {
+	data := "[section1]\nkey1=" + strings.Repeat("x", bufio.MaxScanTokenSize) + "\n"
+	err := NewIni().LoadReaderE(strings.NewReader(data))
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Line != 2 || pe.Err != bufio.ErrTooLong {
+		t.Errorf("read error is not reported: %v", err)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	data := "[section1\nkey1=value1\n=value2\ngarbage\nkey3 = \"\"\"not\nterminated"
+	ini := NewIni()
+	if err := ini.LoadStringE(data); err == nil {
+		t.Error("garbage line is not reported in lenient mode")
+	}
+
+	ini = NewIni()
+	ini.SetStrict(true)
+	err := ini.LoadStringE(data)
+	errs, ok := err.(ParseErrors)
+	if !ok || len(errs) != 4 {
+		t.Fatalf("wrong errors in strict mode: %v", err)
+	}
+	lines := []int{1, 3, 4, 5}
+	for i, pe := range errs {
+		if pe.Line != lines[i] {
+			t.Errorf("expect error at line %d, but it is at line %d", lines[i], pe.Line)
+		}
+	}
+
+	ini = NewIni()
+	ini.SetStrict(true)
+	err = ini.LoadStringE("[section1]\nkey1 = value1 \\")
+	if errs, ok := err.(ParseErrors); !ok || len(errs) != 1 || errs[0].Line != 2 {
+		t.Errorf("dangling continuation char is not reported: %v", err)
+	}
+}
+
+func TestOrderPreserved(t *testing.T) {
+	data := "[zeta]\nkey3=3\nkey1=1\nkey2=2\n[alpha]\nb=1\na=2\n[mid]\nx=1\n[zeta]\nkey0=0\n"
+	ini := Load(data)
+	expect := "[zeta]\nkey3=3\nkey1=1\nkey2=2\nkey0=0\n[alpha]\nb=1\na=2\n[mid]\nx=1\n"
+	for i := 0; i < 10; i++ {
+		if ini.String() != expect {
+			t.Fatalf("order is not preserved:\n%s", ini.String())
+		}
+	}
+
+	ini.AddSection(NewSection("alpha"))
+	names := make([]string, 0)
+	for _, section := range ini.Sections() {
+		names = append(names, section.Name)
+	}
+	if strings.Join(names, ",") != "zeta,alpha,mid" {
+		t.Errorf("the replaced section does not keep its position: %v", names)
+	}
+}
+
+func TestComments(t *testing.T) {
+	data := `# file comment
+
+# comment of section1
+[section1] ; inline of section1
+# deprecated
+#  use key2 instead
+key1 = value1 ; inline of key1
+key2 = value2
+`
+	ini := Load(data)
+	section, _ := ini.GetSection("section1")
+	if section.Comment() != "comment of section1" || section.InlineComment() != "inline of section1" {
+		t.Errorf("wrong comments of section: %q %q", section.Comment(), section.InlineComment())
+	}
+	key1 := section.Key("key1")
+	if key1.Comment() != "deprecated\n use key2 instead" || key1.InlineComment() != "inline of key1" {
+		t.Errorf("wrong comments of key: %q %q", key1.Comment(), key1.InlineComment())
+	}
+	if section.Key("key2").Comment() != "" {
+		t.Error("the key2 should have no comment")
+	}
+
+	section.Key("key2").SetComment("the key2")
+	expect := `# comment of section1
+[section1] # inline of section1
+# deprecated
+#  use key2 instead
+key1=value1 # inline of key1
+# the key2
+key2=value2
+`
+	if ini.String() != expect {
+		t.Errorf("fail to write comments:\n%s", ini.String())
+	}
+	if Load(expect).String() != expect {
+		t.Error("fail to read the written comments")
+	}
+}
+
+func TestMultiValueKeys(t *testing.T) {
+	data := "[program:test]\nserver = a\nport = 1\nserver = b\nserver = c\n"
+	ini := Load(data)
+	if v := ini.GetValueWithDefault("program:test", "server", ""); v != "c" {
+		t.Errorf("the last value should win by default: %s", v)
+	}
+
+	ini = NewIni()
+	ini.SetMultiValueKeys(true)
+	ini.LoadString(data)
+	section, _ := ini.GetSection("program:test")
+	if values := section.Key("server").Values(); strings.Join(values, ",") != "a,b,c" {
+		t.Errorf("wrong values: %v", values)
+	}
+	if v, _ := section.GetValue("server"); v != "c" {
+		t.Errorf("wrong value: %s", v)
+	}
+	section.AddValue("port", "2")
+	expect := "[program:test]\nserver=a\nserver=b\nserver=c\nport=1\nport=2\n"
+	if ini.String() != expect {
+		t.Errorf("fail to write multiple values:\n%s", ini.String())
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	data := "[a]\nx = 1\ny = 2\n[b]\nz = 3\n[a]\nx = 4\n  more\nw = 5\n"
+
+	ini := Load(data)
+	section, _ := ini.GetSection("a")
+	if v, _ := section.GetValue("x"); v != "4\nmore" || !section.HasKey("y") || !section.HasKey("w") {
+		t.Errorf("the sections should be merged by default: %s", ini.String())
+	}
+
+	ini = NewIni()
+	ini.SetDuplicateSectionPolicy(DuplicateLastWins)
+	ini.LoadString(data)
+	section, _ = ini.GetSection("a")
+	if section.HasKey("y") || !section.HasKey("w") || ini.Sections()[0] != section {
+		t.Errorf("the last section should win: %s", ini.String())
+	}
+
+	ini = NewIni()
+	ini.SetDuplicateSectionPolicy(DuplicateFirstWins)
+	ini.SetDuplicateKeyPolicy(DuplicateFirstWins)
+	ini.LoadString(data)
+	section, _ = ini.GetSection("a")
+	if v, _ := section.GetValue("x"); v != "1" || section.HasKey("w") {
+		t.Errorf("the first section should win: %s", ini.String())
+	}
+
+	ini = NewIni()
+	ini.SetDuplicateKeyPolicy(DuplicateFirstWins)
+	ini.LoadString(data)
+	section, _ = ini.GetSection("a")
+	if v, _ := section.GetValue("x"); v != "1" || !section.HasKey("w") {
+		t.Errorf("the first key should win: %s", ini.String())
+	}
+
+	ini = NewIni()
+	ini.SetDuplicateSectionPolicy(DuplicateError)
+	err := ini.LoadStringE(data)
+	if pe, ok := err.(*ParseError); !ok || pe.Line != 6 || !strings.Contains(pe.Msg, "line 1") {
+		t.Errorf("the duplicate section should be reported: %v", err)
+	}
+
+	ini = NewIni()
+	ini.SetDuplicateKeyPolicy(DuplicateError)
+	err = ini.LoadStringE(data)
+	if pe, ok := err.(*ParseError); !ok || pe.Line != 7 || !strings.Contains(pe.Msg, "line 2") {
+		t.Errorf("the duplicate key should be reported: %v", err)
+	}
+	section, _ = ini.GetSection("a")
+	if v, _ := section.GetValue("x"); v != "1" {
+		t.Errorf("the duplicate key should be ignored: %s", v)
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	data := `[paths]
+root = /opt/app
+logs = ${root}/logs
+[server]
+log = ${paths.logs}/server.log
+port = ${base_port}
+base_port = 8080
+missing = a${no.such.key}b
+a = ${b}
+b = ${c}
+c = ${a}
+literal = X
+escaped = $${literal} $$HOME
+`
+	ini := NewIni()
+	ini.SetInterpolation(KeyInterpolation{})
+	ini.LoadString(data)
+	if v, err := ini.GetValue("server", "escaped"); err != nil || v != "${literal} $HOME" {
+		t.Errorf("the $$ should be unescaped by the interpolation: %s, %v", v, err)
+	}
+	if v, err := ini.GetValue("server", "log"); err != nil || v != "/opt/app/logs/server.log" {
+		t.Errorf("fail to expand the references: %s, %v", v, err)
+	}
+	if i, err := ini.GetInt("server", "port"); err != nil || i != 8080 {
+		t.Errorf("fail to get the int value: %d, %v", i, err)
+	}
+	if v, _ := ini.GetValue("server", "missing"); v != "ab" {
+		t.Errorf("the missing reference should be removed: %s", v)
+	}
+	_, err := ini.GetValue("server", "a")
+	if err == nil || !strings.Contains(err.Error(), "server.a -> server.b -> server.c -> server.a") {
+		t.Errorf("the cycle should be reported: %v", err)
+	}
+	if _, err := ini.GetInt("server", "b"); err == nil {
+		t.Error("the cycle should be reported by the typed getter")
+	}
+	if !strings.Contains(ini.String(), "log=${paths.logs}/server.log") {
+		t.Errorf("the references should be written as they are:\n%s", ini.String())
+	}
+
+	deep := NewIni()
+	deep.SetInterpolation(KeyInterpolation{})
+	section := deep.NewSection("deep")
+	section.Add("k0", "end")
+	for i := 1; i <= maxInterpolationDepth; i++ {
+		section.Add(fmt.Sprintf("k%d", i), fmt.Sprintf("${k%d}", i-1))
+	}
+	if _, err := section.GetValue(fmt.Sprintf("k%d", maxInterpolationDepth-1)); err != nil {
+		t.Errorf("fail to expand the nested references: %v", err)
+	}
+	if _, err := section.GetValue(fmt.Sprintf("k%d", maxInterpolationDepth)); err == nil {
+		t.Error("the depth limit should be reported")
+	}
+}
+
+func TestPythonInterpolation(t *testing.T) {
+	basic := `[DEFAULT]
+home = /home/%(user)s
+[paths]
+user = test
+data = %(home)s/data
+ratio = 50%%
+bad = 50%
+`
+	ini := NewIni()
+	ini.SetInterpolation(BasicInterpolation{})
+	ini.LoadString(basic)
+	if v, err := ini.GetValue("paths", "data"); err != nil || v != "/home/test/data" {
+		t.Errorf("fail to expand the basic interpolation: %s, %v", v, err)
+	}
+	if v, _ := ini.GetValue("paths", "ratio"); v != "50%" {
+		t.Errorf("fail to unescape %%%%: %s", v)
+	}
+	if _, err := ini.GetValue("paths", "bad"); err == nil {
+		t.Error("the single % should be reported")
+	}
+	if v, err := ini.GetValue("paths", "HOME"); err != nil || v != "/home/test" || !ini.HasKey("paths", "home") {
+		t.Errorf("the key of the default section should be got in the section: %s, %v", v, err)
+	}
+	if _, err := ini.GetValue("paths", "no-such-key"); err == nil {
+		t.Error("the missing key should be reported")
+	}
+
+	ini = NewIni()
+	ini.SetInterpolation(BasicInterpolation{})
+	ini.LoadString("[DEFAULT]\nBaseDir = /x\n[s]\nUser = test\np = %(basedir)s/%(USER)s\nq = ${s:BASEDIR}")
+	if v, err := ini.GetValue("s", "p"); err != nil || v != "/x/test" {
+		t.Errorf("the key names should be case insensitive: %s, %v", v, err)
+	}
+	ini.SetInterpolation(ExtendedInterpolation{})
+	if v, err := ini.GetValue("s", "q"); err != nil || v != "/x" {
+		t.Errorf("the key names should be case insensitive: %s, %v", v, err)
+	}
+
+	extended := `[DEFAULT]
+root = /opt
+[common]
+dir = ${root}/common
+[app]
+lib = ${common:dir}/lib
+price = $$5
+home = ${HOME}
+`
+	ini = NewIni()
+	ini.SetInterpolation(ExtendedInterpolation{})
+	ini.LoadString(extended)
+	if v, err := ini.GetValue("app", "lib"); err != nil || v != "/opt/common/lib" {
+		t.Errorf("fail to expand the extended interpolation: %s, %v", v, err)
+	}
+	if v, _ := ini.GetValue("app", "price"); v != "$5" {
+		t.Errorf("fail to unescape $$: %s", v)
+	}
+	if _, err := ini.GetValue("app", "home"); err == nil {
+		t.Error("the environment variable should not be expanded")
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.ini":     "[a]\nx = 1\n!include common.ini\ny = 2\n!includedir conf.d\n[c]\ninclude = other/*.ini\n",
+		"common.ini":   "z = 3\n[b]\nw = 4\n",
+		"conf.d/2.ini": "[a]\nx = 20\n",
+		"conf.d/1.ini": "[a]\nx = 10\n",
+		"conf.d/1.txt": "[a]\nx = 30\n",
+		"other/o.ini":  "k = v\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ini := NewIni()
+	ini.SetIncludeFiles(true)
+	if err := ini.LoadFileE(filepath.Join(dir, "main.ini")); err != nil {
+		t.Fatalf("fail to load with the included files: %v", err)
+	}
+	if v, _ := ini.GetValue("a", "z"); v != "3" {
+		t.Errorf("the included keys should be in the current section: %s", v)
+	}
+	if v, _ := ini.GetValue("b", "y"); v != "2" {
+		t.Errorf("the section of the included file should be continued: %s", v)
+	}
+	if v, _ := ini.GetValue("a", "x"); v != "20" {
+		t.Errorf("the files in the directory should be included in order: %s", v)
+	}
+	if v, _ := ini.GetValue("c", "k"); v != "v" || ini.HasKey("c", "include") {
+		t.Errorf("the git style include is not processed: %s", v)
+	}
+
+	os.WriteFile(filepath.Join(dir, "common.ini"), []byte("[b]\nbad line\n!include main.ini\n"), 0644)
+	ini = NewIni()
+	ini.SetIncludeFiles(true)
+	ini.SetStrict(true)
+	err := ini.LoadFileE(filepath.Join(dir, "main.ini"))
+	errs, ok := err.(ParseErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("the problems of the included files should be reported: %v", err)
+	}
+	if len(errs[0].Includes) != 1 || !strings.HasSuffix(errs[0].Includes[0], "main.ini:3") {
+		t.Errorf("the include chain should be reported: %v", errs[0])
+	}
+	if !strings.Contains(errs[1].Msg, "include cycle: ") {
+		t.Errorf("the include cycle should be reported: %v", errs[1])
+	}
+
+	ini = NewIni()
+	ini.LoadFile(filepath.Join(dir, "main.ini"))
+	if !ini.HasKey("c", "include") {
+		t.Error("the include directives should be ignored by default")
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults/app.ini":    {Data: []byte("[app]\nname = test\n!include /common/log.ini\n")},
+		"defaults/db.ini":     {Data: []byte("[db]\nhost = localhost\n")},
+		"common/log.ini":      {Data: []byte("level = info\n!includedir ../conf.d\n")},
+		"conf.d/override.ini": {Data: []byte("[db]\nhost = db\n")},
+	}
+	ini := NewIni()
+	ini.SetIncludeFiles(true)
+	if err := ini.LoadFSE(fsys, "defaults/*.ini"); err != nil {
+		t.Fatalf("fail to load from fs: %v", err)
+	}
+	if v, _ := ini.GetValue("app", "level"); v != "info" {
+		t.Errorf("fail to include from fs: %s", v)
+	}
+	if v, _ := ini.GetValue("db", "host"); v != "localhost" {
+		t.Errorf("the files should be loaded in order: %s", v)
+	}
+	if err := ini.LoadFSE(fsys, "defaults/not-exist.ini"); err == nil {
+		t.Error("the missing file should be reported")
+	}
+}
+
+func TestLoadSource(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.ini")
+	os.WriteFile(file, []byte("[a]\nx = 1\n"), 0644)
+	missing := filepath.Join(dir, "missing.ini")
+
+	ini := NewIni()
+	err := ini.LoadE(FileSource(file),
+		OptionalFileSource(missing),
+		ContentSource(file+" = 1"),
+		BytesSource([]byte("[b]\ny = 2\n")),
+		ReaderSource(strings.NewReader("[c]\nz = 3\n")))
+	if err != nil {
+		t.Fatalf("fail to load the sources: %v", err)
+	}
+	if !ini.HasKey("a", "x") || !ini.HasKey("b", "y") || !ini.HasKey("c", "z") {
+		t.Errorf("the sources are not loaded:\n%s", ini.String())
+	}
+	if !ini.HasKey(ini.GetDefaultSectionName(), file) {
+		t.Error("the content source should not be taken as a file")
+	}
+	if err := ini.LoadE(FileSource(missing)); !os.IsNotExist(err) {
+		t.Errorf("the missing file should be reported: %v", err)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "10-base.ini"), []byte("[db]\nhost = localhost\nport = 5432\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "20-prod.ini"), []byte("[db]\nhost = db\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "30-skip.bak"), []byte("[db]\nhost = bak\n"), 0644)
+
+	ini := NewIni()
+	if err := ini.LoadDirE(dir, ""); err != nil {
+		t.Fatalf("fail to load the directory: %v", err)
+	}
+	section, _ := ini.GetSection("db")
+	if v, _ := section.GetValue("host"); v != "db" {
+		t.Errorf("the later file should override the key: %s", v)
+	}
+	if s := section.Key("host").Origin().Source; filepath.Base(s) != "20-prod.ini" {
+		t.Errorf("wrong source of the key: %s", s)
+	}
+	if s := section.Key("port").Origin().Source; filepath.Base(s) != "10-base.ini" {
+		t.Errorf("wrong source of the key: %s", s)
+	}
+
+	ini = NewIni()
+	ini.SetDuplicateKeyPolicy(DuplicateError)
+	if err := ini.LoadDirE(dir, "*.ini"); err == nil {
+		t.Error("the duplicate key should be reported by the policy")
+	}
+	if err := NewIni().LoadDirE(filepath.Join(dir, "not-exist"), ""); err == nil {
+		t.Error("the missing directory should be reported")
+	}
+}
+
+func TestLoadDirFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf.d/10-base.ini": {Data: []byte("[db]\nhost = localhost\nport = 5432\n")},
+		"conf.d/20-prod.ini": {Data: []byte("[db]\nhost = db\n")},
+		"conf.d/30-skip.bak": {Data: []byte("[db]\nhost = bak\n")},
+	}
+	ini := NewIni()
+	if err := ini.LoadDirFSE(fsys, "conf.d", ""); err != nil {
+		t.Fatalf("fail to load the directory: %v", err)
+	}
+	if v, _ := ini.GetValue("db", "host"); v != "db" {
+		t.Errorf("the later file should override the key: %s", v)
+	}
+	if v, _ := ini.GetValue("db", "port"); v != "5432" {
+		t.Errorf("wrong value of the key: %s", v)
+	}
+	if err := NewIni().LoadDirFSE(fsys, "not-exist", ""); err == nil {
+		t.Error("the missing directory should be reported")
+	}
+}
+
+func TestKeyOrigin(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.ini")
+	prod := filepath.Join(dir, "prod.ini")
+	os.WriteFile(base, []byte("[db]\nport = 5432\n"), 0644)
+	os.WriteFile(prod, []byte("[db]\n\nport = x5433\n"), 0644)
+
+	ini, err := LoadE(FileSource(base), FileSource(prod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, _ := ini.GetSection("db")
+	origin := section.Key("port").Origin()
+	if origin.Source != prod || origin.Line != 3 || len(origin.Overridden) != 1 ||
+		origin.Overridden[0].String() != base+":2" {
+		t.Errorf("wrong origin of the key: %v", origin)
+	}
+	_, err = section.Key("port").Int()
+	if err == nil || !strings.Contains(err.Error(), prod+":3") {
+		t.Errorf("the origin should be in the error: %v", err)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("the error should wrap the conversion error: %v", err)
+	}
+}
+
+func TestEnvOverride(t *testing.T) {
+	ini := NewIni()
+	ini.SetEnvOverride(&EnvOverride{Prefix: "MYAPP", Resolver: MapResolver{
+		"MYAPP_DATABASE_HOST":     "db.example.com",
+		"MYAPP_DATABASE_LOG_FILE": "/var/log/db.log",
+		"MYAPP_NAME":              "test",
+		"MYAPP_DATABASE_PASSWORD": "se$cret${host}",
+	}})
+	ini.SetResolver(MapResolver{})
+	ini.SetInterpolation(KeyInterpolation{})
+	ini.Load("name = app\n[database]\n# the host\nhost = localhost\nport = 5432\nlog-file = db.log\npassword = x\nurl = ${host}:${port}")
+
+	expects := map[string]string{"host": "db.example.com", "port": "5432", "log-file": "/var/log/db.log", "url": "db.example.com:5432", "password": "se$cret${host}"}
+	for key, expect := range expects {
+		if v, err := ini.GetValue("database", key); err != nil || v != expect {
+			t.Errorf("wrong value of %s: %s, %v", key, v, err)
+		}
+	}
+	if v, _ := ini.GetValue(ini.GetDefaultSectionName(), "name"); v != "test" {
+		t.Errorf("the key in the default section should be overridden: %s", v)
+	}
+	section, _ := ini.GetSection("database")
+	k := section.Key("host")
+	if k.Comment() != "the host" || k.Origin().String() != "$MYAPP_DATABASE_HOST" || len(k.Origin().Overridden) != 1 {
+		t.Errorf("wrong key overridden: %s, %v", k.Comment(), k.Origin())
+	}
+	for _, key := range section.Keys() {
+		if v, _ := key.Value(); v != expects[key.Name()] {
+			t.Errorf("the keys should be overridden: %s = %s", key.Name(), v)
+		}
+	}
+	if !strings.Contains(ini.String(), "host=localhost") {
+		t.Errorf("the overridden value should not be written: %s", ini.String())
+	}
+
+	ini.SetEnvOverride(&EnvOverride{Separator: "__", Case: EnvLowerCase, Resolver: MapResolver{"database__port": "6543"}})
+	if v, _ := ini.GetInt("database", "port"); v != 6543 {
+		t.Errorf("wrong value of port: %d", v)
+	}
+	ini.SetEnvOverride(nil)
+	if v, _ := ini.GetInt("database", "port"); v != 5432 {
+		t.Errorf("wrong value of port: %d", v)
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	ini := NewIni()
+	ini.SetEnvOverride(&EnvOverride{Resolver: MapResolver{"DB_HOST": "env.example.com", "DB_PORT": "6543"}})
+	ini.Load("verbose = false\n[db]\n; the host of the database\nhost = localhost\nport = 5432\nuser = root\npassword = x")
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	user := flagSet.String("db.user", "admin", "defined by hand")
+	ini.BindFlags(flagSet)
+	f := flagSet.Lookup("db.host")
+	if f == nil || f.DefValue != "env.example.com" || f.Usage != "the host of the database" {
+		t.Fatalf("wrong flag of the key: %v", f)
+	}
+	args := []string{"-verbose", "-db.host", "db.example.com", "-db.user", "test", "-db.password", "pa$$w${UNSET:?msg}"}
+	if err := flagSet.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := ini.GetBool(ini.GetDefaultSectionName(), "verbose"); !v {
+		t.Errorf("the bool flag should be set")
+	}
+	if v, _ := ini.GetValue("db", "host"); v != "db.example.com" {
+		t.Errorf("the flag should override the environment variable: %s", v)
+	}
+	if v, _ := ini.GetValue("db", "port"); v != "6543" {
+		t.Errorf("the key without flag set should not be changed: %s", v)
+	}
+	if v, err := ini.GetValue("db", "password"); err != nil || v != "pa$$w${UNSET:?msg}" {
+		t.Errorf("the flag value should not be expanded: %s, %v", v, err)
+	}
+	if v, _ := ini.GetValue("db", "user"); v != "root" || *user != "test" {
+		t.Errorf("the flag defined by hand should be skipped: %s, %s", v, *user)
+	}
+	section, _ := ini.GetSection("db")
+	origin := section.Key("host").Origin()
+	if origin.Source != "-db.host" || len(origin.Overridden) != 1 || origin.Overridden[0].Line != 4 {
+		t.Errorf("wrong origin of the key: %v", origin)
+	}
+}
@@ -0,0 +1,251 @@
+package ini
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// the max depth of the nested references in a value
+const maxInterpolationDepth = 10
+
+// Interpolation expands the references to other keys in the value of a key
+type Interpolation interface {
+	// expand the references in the value of a key in the section, the value
+	// of a referenced key is got by lookup with the references in it expanded
+	Interpolate(section string, value string, lookup LookupFunc) (string, error)
+}
+
+// LookupFunc gets the expanded value of the key in the section, found is
+// false if the key does not exist
+type LookupFunc func(section string, key string) (value string, found bool, err error)
+
+// KeyInterpolation expands ${key} with the value of the key in the same section
+// and ${section.key} with the value of the key in another section, and $$ is
+// replaced with a single $. A reference to a key not found is removed like an
+// environment variable not found, and the environment variables take
+// precedence over the keys with the same name
+type KeyInterpolation struct {
+}
+
+func (KeyInterpolation) Interpolate(section string, value string, lookup LookupFunc) (string, error) {
+	n := len(value)
+	result := bytes.NewBuffer(make([]byte, 0))
+	for i := 0; i < n; i++ {
+		switch {
+		case value[i] == '\\' && i+1 < n:
+			result.WriteString(value[i : i+2])
+			i++
+		case strings.HasPrefix(value[i:], "$$"):
+			result.WriteByte('$')
+			i++
+		case strings.HasPrefix(value[i:], "${"):
+			end := strings.IndexByte(value[i:], '}')
+			if end == -1 {
+				result.WriteString(value[i:])
+				return result.String(), nil
+			}
+			name := value[i+2 : i+end]
+			v, found, err := lookup(section, name)
+			if !found && err == nil {
+				if pos := strings.LastIndex(name, "."); pos > 0 {
+					v, found, err = lookup(name[0:pos], name[pos+1:])
+				}
+			}
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(v)
+			i += end
+		default:
+			result.WriteByte(value[i])
+		}
+	}
+	return result.String(), nil
+}
+
+// interpolator expands the references in the value of a key recursively
+// with the interpolation of the ini
+type interpolator struct {
+	ini *Ini
+	// the keys being expanded as "section.key", used to detect the cycle
+	stack []string
+}
+
+func (ip *interpolator) expand(section string, key string, value string) (string, error) {
+	name := section + "." + key
+	for i, s := range ip.stack {
+		if s == name {
+			cycle := append(append(make([]string, 0), ip.stack[i:]...), name)
+			return "", fmt.Errorf("interpolation cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if len(ip.stack) >= maxInterpolationDepth {
+		return "", fmt.Errorf("interpolation of %s exceeds the max depth %d", ip.stack[0], maxInterpolationDepth)
+	}
+	ip.stack = append(ip.stack, name)
+	defer func() {
+		ip.stack = ip.stack[0 : len(ip.stack)-1]
+	}()
+	return ip.ini.interpolation.Interpolate(section, value, ip.lookup)
+}
+
+func (ip *interpolator) lookup(section string, key string) (string, bool, error) {
+	var k *normalKey
+	s, ok := ip.ini.sections[section]
+	if ok {
+		k, ok = s.findKey(key)
+	}
+	if !ok {
+		k, ok = ip.defaultKey(section, key)
+	}
+	if !ok {
+		return "", false, nil
+	}
+	value, err := k.envValue()
+	if err == nil && !k.literal {
+		value, err = ip.expand(section, k.name, value)
+	}
+	return value, true, err
+}
+
+// find the key in the default section of the python dialects
+func (ip *interpolator) defaultKey(section string, key string) (*normalKey, bool) {
+	dialect, ok := ip.ini.interpolation.(pythonDialect)
+	if !ok || section == dialect.defaultSection() {
+		return nil, false
+	}
+	s, ok := ip.ini.sections[dialect.defaultSection()]
+	if !ok {
+		return nil, false
+	}
+	return s.foldKey(key)
+}
+
+// the name of the section providing the default values in python configparser
+const pythonDefaultSection = "DEFAULT"
+
+// BasicInterpolation expands %(key)s with the value of the key in the same
+// section as python configparser.BasicInterpolation, and %% is replaced with
+// a single %. The key names are case insensitive, and the key not found in
+// the section is looked up in the section DefaultSection, which is "DEFAULT"
+// if it is empty, and expanded in the section. The environment variables are
+// not expanded with this interpolation
+type BasicInterpolation struct {
+	DefaultSection string
+}
+
+func (ip BasicInterpolation) Interpolate(section string, value string, lookup LookupFunc) (string, error) {
+	result := bytes.NewBuffer(make([]byte, 0))
+	for {
+		pos := strings.IndexByte(value, '%')
+		if pos == -1 {
+			result.WriteString(value)
+			return result.String(), nil
+		}
+		result.WriteString(value[0:pos])
+		value = value[pos:]
+		switch {
+		case strings.HasPrefix(value, "%%"):
+			result.WriteByte('%')
+			value = value[2:]
+		case strings.HasPrefix(value, "%("):
+			end := strings.IndexByte(value, ')')
+			if end == -1 || !strings.HasPrefix(value[end:], ")s") {
+				return "", fmt.Errorf("bad interpolation variable reference %q", value)
+			}
+			v, err := lookupKey(lookup, section, value[2:end])
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(v)
+			value = value[end+2:]
+		default:
+			return "", fmt.Errorf("'%%' must be followed by '%%' or '(', found: %q", value)
+		}
+	}
+}
+
+func (ip BasicInterpolation) defaultSection() string {
+	return pythonDefaultName(ip.DefaultSection)
+}
+
+// ExtendedInterpolation expands ${key} with the value of the key in the same
+// section and ${section:key} with the value of the key in another section as
+// python configparser.ExtendedInterpolation, and $$ is replaced with a single
+// $. The key names are case insensitive, and the key not found in a section
+// is looked up in the section DefaultSection, which is "DEFAULT" if it is
+// empty, and expanded in that section. The environment variables are not
+// expanded with this interpolation
+type ExtendedInterpolation struct {
+	DefaultSection string
+}
+
+func (ip ExtendedInterpolation) Interpolate(section string, value string, lookup LookupFunc) (string, error) {
+	result := bytes.NewBuffer(make([]byte, 0))
+	for {
+		pos := strings.IndexByte(value, '$')
+		if pos == -1 {
+			result.WriteString(value)
+			return result.String(), nil
+		}
+		result.WriteString(value[0:pos])
+		value = value[pos:]
+		switch {
+		case strings.HasPrefix(value, "$$"):
+			result.WriteByte('$')
+			value = value[2:]
+		case strings.HasPrefix(value, "${"):
+			end := strings.IndexByte(value, '}')
+			if end == -1 {
+				return "", fmt.Errorf("bad interpolation variable reference %q", value)
+			}
+			path := strings.Split(value[2:end], ":")
+			var v string
+			var err error
+			switch len(path) {
+			case 1:
+				v, err = lookupKey(lookup, section, path[0])
+			case 2:
+				v, err = lookupKey(lookup, path[0], path[1])
+			default:
+				err = fmt.Errorf("more than one ':' found: %q", value[0:end+1])
+			}
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(v)
+			value = value[end+1:]
+		default:
+			return "", fmt.Errorf("'$' must be followed by '$' or '{', found: %q", value)
+		}
+	}
+}
+
+func (ip ExtendedInterpolation) defaultSection() string {
+	return pythonDefaultName(ip.DefaultSection)
+}
+
+// pythonDialect is implemented by the interpolation of python configparser.
+// The environment variables are not expanded with it because its references
+// may be taken as environment variables, and the key not found in a section
+// is looked up in the default section and expanded in the section
+type pythonDialect interface {
+	defaultSection() string
+}
+
+func pythonDefaultName(name string) string {
+	if len(name) <= 0 {
+		return pythonDefaultSection
+	}
+	return name
+}
+
+// lookup the key in the section, return error if the key is not found
+func lookupKey(lookup LookupFunc, section string, key string) (string, error) {
+	value, found, err := lookup(section, key)
+	if err == nil && !found {
+		err = fmt.Errorf("no key %s in section %s for the interpolation", key, section)
+	}
+	return value, err
+}
@@ -13,9 +13,20 @@ type Key interface {
 	// get name of the key
 	Name() string
 
-	// get value of the key
+	// get value of the key as it is in the file without expanding the
+	// variables and the references to other keys, the last value is
+	// returned if the key has multiple values
+	Raw() string
+	// get value of the key, the last value is returned if the key
+	// has multiple values. The references to other keys are expanded if
+	// the interpolation is enabled, the value is returned with an error if
+	// the references can't be expanded
 	Value() (string, error)
 
+	// get all the values of the key if the key is loaded in multi-value
+	// mode or added by Section.AddValue()
+	Values() []string
+
 	//get the value of key and return defValue if
 	//the value does not exist
 	ValueWithDefault(defValue string) string
@@ -75,7 +86,45 @@ type Key interface {
 	// if the value of the key does not exist
 	Float64WithDefault(defValue float64) float64
 
-	// return a string as "key=value" format
+	// get the value as a list splitted by the delimiter sep, "," is
+	// used if the sep is empty. The elements are trimmed, and an element
+	// can include the delimiter by escaping it with '\' or putting the
+	// element in quotes. Return nil if the key does not exist
+	Strings(sep string) []string
+
+	// get the value as a list of int
+	Ints(sep string) ([]int, error)
+
+	// get the value as a list of int64
+	Int64s(sep string) ([]int64, error)
+
+	// get the value as a list of uint64
+	Uint64s(sep string) ([]uint64, error)
+
+	// get the value as a list of float64
+	Float64s(sep string) ([]float64, error)
+
+	// get the value as a list of bool
+	Bools(sep string) ([]bool, error)
+
+	// get where the key is defined, e.g. the file name and line, and the
+	// earlier definitions overridden by it
+	Origin() Origin
+	// get the comment lines before the key, the leading ';' or '#'
+	// is removed from each line and the lines are joined with '\n'
+	Comment() string
+
+	// set the comment lines before the key, multiple lines should
+	// be separated with '\n'
+	SetComment(comment string)
+
+	// get the inline comment after the value
+	InlineComment() string
+
+	// set the inline comment after the value
+	SetInlineComment(comment string)
+
+	// return a string as "key=value" format, one line for each value
 	// and if no value return empty string
 	String() string
 }
@@ -96,6 +145,14 @@ func (nek *nonExistKey) Value() (string, error) {
 	return "", nek.noSuchKey()
 }
 
+func (nek *nonExistKey) Raw() string {
+	return ""
+}
+
+func (nek *nonExistKey) Values() []string {
+	return nil
+}
+
 func (nek *nonExistKey) ValueWithDefault(defValue string) string {
 	return defValue
 }
@@ -156,6 +213,48 @@ func (nek *nonExistKey) Float64WithDefault(defValue float64) float64 {
 	return defValue
 }
 
+func (nek *nonExistKey) Strings(sep string) []string {
+	return nil
+}
+
+func (nek *nonExistKey) Ints(sep string) ([]int, error) {
+	return nil, nek.noSuchKey()
+}
+
+func (nek *nonExistKey) Int64s(sep string) ([]int64, error) {
+	return nil, nek.noSuchKey()
+}
+
+func (nek *nonExistKey) Uint64s(sep string) ([]uint64, error) {
+	return nil, nek.noSuchKey()
+}
+
+func (nek *nonExistKey) Float64s(sep string) ([]float64, error) {
+	return nil, nek.noSuchKey()
+}
+
+func (nek *nonExistKey) Bools(sep string) ([]bool, error) {
+	return nil, nek.noSuchKey()
+}
+
+func (nek *nonExistKey) Origin() Origin {
+	return Origin{}
+}
+
+func (nek *nonExistKey) Comment() string {
+	return ""
+}
+
+func (nek *nonExistKey) SetComment(comment string) {
+}
+
+func (nek *nonExistKey) InlineComment() string {
+	return ""
+}
+
+func (nek *nonExistKey) SetInlineComment(comment string) {
+}
+
 func (nek *nonExistKey) String() string {
 	return ""
 }
@@ -165,30 +264,193 @@ func (nek *nonExistKey) noSuchKey() error {
 }
 
 type normalKey struct {
-	name  string
+	name string
+	// the raw value, the last one if the key has multiple values
 	value string
+	// the section the key belongs to, may be nil
+	section *Section
+	// all the raw values if the key has multiple values, or nil
+	values []string
+	// all the values with the variables expanded when they are added,
+	// nil in lazy expansion mode
+	expanded []string
+	// the errors of expanding the values when they are added, the value
+	// which can't be expanded is kept as it is in expanded
+	expandErrs []error
+	// the original text of the key in preserve format mode
+	node *docNode
+	// comment lines before the key and inline comment after the value
+	comment       string
+	inlineComment string
+	// where the key is loaded from, the line is 0 if it is not loaded
+	source string
+	line   int
+	// the earlier definitions overridden by the key, the latest first
+	overridden []Origin
+	// the value is set by a command line flag, it is not overridden by the
+	// environment variables
+	fromFlag bool
+	// the value is a literal set by an override, the variables and
+	// references in it are not expanded
+	literal bool
+}
+
+// Origin tells where a key is defined
+type Origin struct {
+	// name of the source, e.g. the file name, may be empty
+	Source string
+	// line number, starts from 1. It is 0 if the key is not loaded
+	Line int
+	// the earlier definitions overridden by this one, the latest first
+	Overridden []Origin
+}
+
+// return the position as "source:line", the source if the line is unknown
+// or "unknown position" if the key is not loaded
+func (o Origin) String() string {
+	return definedAt(o.Source, o.Line)
 }
 
 var trueBoolValue = map[string]bool{"true": true, "t": true, "yes": true, "y": true, "1": true}
 
-func newNormalKey(name, value string) *normalKey {
-	return &normalKey{name: name, value: replace_env(value)}
+func newNormalKey(section *Section, name, value string) *normalKey {
+	k := &normalKey{name: name, value: value, section: section}
+	if ini := k.ini(); ini == nil || !ini.lazyExpansion {
+		k.expanded = []string{""}
+		k.replaceEnv(0, value)
+	}
+	return k
+}
+
+// replace the environment variables in the value and keep it as the i-th
+// expanded value, the value is kept as it is with the error if it can't be
+// expanded
+func (k *normalKey) replaceEnv(i int, value string) {
+	for len(k.expandErrs) < len(k.expanded) {
+		k.expandErrs = append(k.expandErrs, nil)
+	}
+	k.expanded[i], k.expandErrs[i] = k.ini().expandEnv(value)
+}
+
+// get the error of expanding the i-th value when it is added
+func (k *normalKey) expandErr(i int) error {
+	if i < len(k.expandErrs) {
+		return k.expandErrs[i]
+	}
+	return nil
+}
+
+// get the last value with the variables expanded
+func (k *normalKey) envValue() (string, error) {
+	if k.expanded != nil {
+		i := len(k.expanded) - 1
+		return k.expanded[i], k.expandErr(i)
+	}
+	return k.ini().expandEnv(k.value)
+}
+
+// get the Ini the key belongs to, may be nil
+func (k *normalKey) ini() *Ini {
+	if k.section == nil {
+		return nil
+	}
+	return k.section.ini
+}
+
+// expand the references to other keys in the value
+func (k *normalKey) interpolate(value string) (string, error) {
+	ini := k.ini()
+	if ini == nil || ini.interpolation == nil || k.literal {
+		return value, nil
+	}
+	ip := &interpolator{ini: ini}
+	return ip.expand(k.section.Name, k.name, value)
 }
 
 func (k *normalKey) Name() string {
 	return k.name
 }
 
+func (k *normalKey) Raw() string {
+	return k.value
+}
+
 func (k *normalKey) Value() (string, error) {
-	return k.value, nil
+	value, err := k.envValue()
+	if err == nil {
+		value, err = k.interpolate(value)
+	}
+	if err != nil {
+		return k.value, err
+	}
+	return value, nil
+}
+
+func (k *normalKey) Values() []string {
+	values := k.rawValues()
+	for i, value := range values {
+		var err error
+		if k.expanded != nil {
+			value, err = k.expanded[i], k.expandErr(i)
+		} else {
+			value, err = k.ini().expandEnv(value)
+		}
+		if err == nil {
+			value, err = k.interpolate(value)
+		}
+		if err == nil {
+			values[i] = value
+		}
+	}
+	return values
+}
+
+// get all the values of the key without expanding the references
+func (k *normalKey) rawValues() []string {
+	if k.values == nil {
+		return []string{k.value}
+	}
+	return append(make([]string, 0), k.values...)
+}
+
+// add a value to the key, the key has multiple values after that
+func (k *normalKey) addValue(value string) {
+	if k.values == nil {
+		k.values = []string{k.value}
+	}
+	k.value = value
+	k.values = append(k.values, value)
+	if k.expanded != nil {
+		k.expanded = append(k.expanded, "")
+		k.replaceEnv(len(k.expanded)-1, value)
+	}
+}
+
+// replace the last value of the key
+func (k *normalKey) setValue(value string) {
+	k.value = value
+	if k.values != nil {
+		k.values[len(k.values)-1] = value
+	}
+	if k.expanded != nil {
+		k.replaceEnv(len(k.expanded)-1, value)
+	}
 }
 
 func (k *normalKey) ValueWithDefault(defValue string) string {
-	return k.value
+	value, err := k.Value()
+	if err != nil {
+		return defValue
+	}
+	return value
 }
 
 func (k *normalKey) Bool() (bool, error) {
-	if _, ok := trueBoolValue[strings.ToLower(k.value)]; ok {
+	value, err := k.Value()
+	if err != nil {
+		return false, k.originError(err)
+	}
+	if _, ok := trueBoolValue[strings.ToLower(value)]; ok {
 		return true, nil
 	}
 	return false, nil
@@ -203,11 +465,16 @@ func (k *normalKey) BoolWithDefault(defValue bool) bool {
 }
 
 func (k *normalKey) Int() (int, error) {
-	return strconv.Atoi(k.value)
+	value, err := k.Value()
+	if err != nil {
+		return 0, k.originError(err)
+	}
+	i, err := strconv.Atoi(value)
+	return i, k.originError(err)
 }
 
 func (k *normalKey) IntWithDefault(defValue int) int {
-	i, err := strconv.Atoi(k.value)
+	i, err := k.Int()
 	if err == nil {
 		return i
 	}
@@ -215,8 +482,12 @@ func (k *normalKey) IntWithDefault(defValue int) int {
 }
 
 func (k *normalKey) Uint() (uint, error) {
-	v, err := strconv.ParseUint(k.value, 0, 32)
-	return uint(v), err
+	value, err := k.Value()
+	if err != nil {
+		return 0, k.originError(err)
+	}
+	v, err := strconv.ParseUint(value, 0, 32)
+	return uint(v), k.originError(err)
 }
 
 func (k *normalKey) UintWithDefault(defValue uint) uint {
@@ -229,11 +500,16 @@ func (k *normalKey) UintWithDefault(defValue uint) uint {
 }
 
 func (k *normalKey) Int64() (int64, error) {
-	return strconv.ParseInt(k.value, 0, 64)
+	value, err := k.Value()
+	if err != nil {
+		return 0, k.originError(err)
+	}
+	i, err := strconv.ParseInt(value, 0, 64)
+	return i, k.originError(err)
 }
 
 func (k *normalKey) Int64WithDefault(defValue int64) int64 {
-	i, err := strconv.ParseInt(k.value, 0, 64)
+	i, err := k.Int64()
 	if err == nil {
 		return i
 	}
@@ -241,11 +517,16 @@ func (k *normalKey) Int64WithDefault(defValue int64) int64 {
 }
 
 func (k *normalKey) Uint64() (uint64, error) {
-	return strconv.ParseUint(k.value, 0, 64)
+	value, err := k.Value()
+	if err != nil {
+		return 0, k.originError(err)
+	}
+	i, err := strconv.ParseUint(value, 0, 64)
+	return i, k.originError(err)
 }
 
 func (k *normalKey) Uint64WithDefault(defValue uint64) uint64 {
-	i, err := strconv.ParseUint(k.value, 0, 64)
+	i, err := k.Uint64()
 	if err == nil {
 		return i
 	}
@@ -253,30 +534,132 @@ func (k *normalKey) Uint64WithDefault(defValue uint64) uint64 {
 }
 
 func (k *normalKey) Float32() (float32, error) {
-	f, err := strconv.ParseFloat(k.value, 32)
-	return float32(f), err
+	value, err := k.Value()
+	if err != nil {
+		return 0, k.originError(err)
+	}
+	f, err := strconv.ParseFloat(value, 32)
+	return float32(f), k.originError(err)
 }
 
 func (k *normalKey) Float32WithDefault(defValue float32) float32 {
-	f, err := strconv.ParseFloat(k.value, 32)
+	f, err := k.Float32()
 	if err == nil {
-		return float32(f)
+		return f
 	}
 	return defValue
 }
 
 func (k *normalKey) Float64() (float64, error) {
-	return strconv.ParseFloat(k.value, 64)
+	value, err := k.Value()
+	if err != nil {
+		return 0, k.originError(err)
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	return f, k.originError(err)
 }
 
 func (k *normalKey) Float64WithDefault(defValue float64) float64 {
-	f, err := strconv.ParseFloat(k.value, 64)
+	f, err := k.Float64()
 	if err == nil {
 		return f
 	}
 	return defValue
 }
 
+func (k *normalKey) Strings(sep string) []string {
+	value, _ := k.Value()
+	return splitList(value, sep)
+}
+
+// split the value to a list, return error if the value can't be expanded
+func (k *normalKey) list(sep string) ([]string, error) {
+	value, err := k.Value()
+	if err != nil {
+		return nil, err
+	}
+	return splitList(value, sep), nil
+}
+
+func (k *normalKey) Ints(sep string) ([]int, error) {
+	values, err := k.list(sep)
+	if err != nil {
+		return nil, k.originError(err)
+	}
+	r, err := parseInts(values)
+	return r, k.originError(err)
+}
+
+func (k *normalKey) Int64s(sep string) ([]int64, error) {
+	values, err := k.list(sep)
+	if err != nil {
+		return nil, k.originError(err)
+	}
+	r, err := parseInt64s(values)
+	return r, k.originError(err)
+}
+
+func (k *normalKey) Uint64s(sep string) ([]uint64, error) {
+	values, err := k.list(sep)
+	if err != nil {
+		return nil, k.originError(err)
+	}
+	r, err := parseUint64s(values)
+	return r, k.originError(err)
+}
+
+func (k *normalKey) Float64s(sep string) ([]float64, error) {
+	values, err := k.list(sep)
+	if err != nil {
+		return nil, k.originError(err)
+	}
+	r, err := parseFloat64s(values)
+	return r, k.originError(err)
+}
+
+func (k *normalKey) Bools(sep string) ([]bool, error) {
+	values, err := k.list(sep)
+	if err != nil {
+		return nil, k.originError(err)
+	}
+	return parseBools(values), nil
+}
+
+func (k *normalKey) Origin() Origin {
+	return Origin{Source: k.source, Line: k.line, Overridden: append([]Origin(nil), k.overridden...)}
+}
+
+// add the name and origin of the key to the error of getting its value
+func (k *normalKey) originError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if k.line <= 0 && len(k.source) <= 0 {
+		return fmt.Errorf("key %s: %w", k.name, err)
+	}
+	return fmt.Errorf("key %s defined at %s: %w", k.name, k.Origin(), err)
+}
+
+func (k *normalKey) Comment() string {
+	return k.comment
+}
+
+func (k *normalKey) SetComment(comment string) {
+	k.comment = comment
+}
+
+func (k *normalKey) InlineComment() string {
+	return k.inlineComment
+}
+
+func (k *normalKey) SetInlineComment(comment string) {
+	k.inlineComment = comment
+}
+
 func (k *normalKey) String() string {
-	return fmt.Sprintf("%s=%s", k.name, toEscape(k.value))
+	lines := make([]string, 0)
+	for _, value := range k.rawValues() {
+		lines = append(lines, fmt.Sprintf("%s=%s", k.name, toEscape(value)))
+	}
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,247 @@
+package ini
+
+import (
+	"io"
+	"sort"
+)
+
+// Layers is a view of multiple Ini ordered by priority, e.g. the defaults, the
+// system file, the user file, the environment and the command line overrides.
+// The value of a key is got from the layer with the highest priority which has
+// the key, and the layers can be added or removed at any time
+type Layers struct {
+	// the layers sorted by priority, the highest one is the last
+	layers []*layer
+}
+
+type layer struct {
+	name     string
+	priority int
+	ini      *Ini
+}
+
+// create an empty layered view
+func NewLayers() *Layers {
+	return &Layers{}
+}
+
+// add the ini as a layer with the name and priority, the layer with the same
+// name is replaced. If two layers have the same priority, the one added later
+// takes precedence
+func (l *Layers) AddLayer(name string, priority int, ini *Ini) {
+	l.RemoveLayer(name)
+	l.layers = append(l.layers, &layer{name: name, priority: priority, ini: ini})
+	sort.SliceStable(l.layers, func(i, j int) bool {
+		return l.layers[i].priority < l.layers[j].priority
+	})
+}
+
+// remove the layer by name
+//
+// return false if the layer does not exist
+func (l *Layers) RemoveLayer(name string) bool {
+	for i, layer := range l.layers {
+		if layer.name == name {
+			l.layers = append(l.layers[0:i], l.layers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// get the ini of a layer by name
+func (l *Layers) GetLayer(name string) (*Ini, bool) {
+	for _, layer := range l.layers {
+		if layer.name == name {
+			return layer.ini, true
+		}
+	}
+	return nil, false
+}
+
+// get the names of all the layers, the one with the highest priority first
+func (l *Layers) LayerNames() []string {
+	names := make([]string, 0)
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		names = append(names, l.layers[i].name)
+	}
+	return names
+}
+
+// find the ini of the layer with the highest priority which has the key. If
+// no layer has the key, the one has the section or an empty ini is returned,
+// so the error of getting the key tells what is missing
+func (l *Layers) find(sectionName, key string) *Ini {
+	var found *Ini
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		ini := l.layers[i].ini
+		if ini.HasKey(sectionName, key) {
+			return ini
+		}
+		if found == nil && ini.HasSection(sectionName) {
+			found = ini
+		}
+	}
+	if found == nil {
+		return NewIni()
+	}
+	return found
+}
+
+// check if any layer has the section
+func (l *Layers) HasSection(name string) bool {
+	for _, layer := range l.layers {
+		if layer.ini.HasSection(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// check if any layer has the key in the section
+func (l *Layers) HasKey(sectionName, key string) bool {
+	return l.find(sectionName, key).HasKey(sectionName, key)
+}
+
+// get the key from the layer with the highest priority which has the key
+func (l *Layers) GetKey(sectionName, key string) Key {
+	if section, err := l.find(sectionName, key).GetSection(sectionName); err == nil {
+		return section.Key(key)
+	}
+	return newNonExistKey(key)
+}
+
+func (l *Layers) GetValue(sectionName, key string) (string, error) {
+	return l.find(sectionName, key).GetValue(sectionName, key)
+}
+
+func (l *Layers) GetValueWithDefault(sectionName, key string, defValue string) string {
+	return l.find(sectionName, key).GetValueWithDefault(sectionName, key, defValue)
+}
+
+func (l *Layers) GetBool(sectionName, key string) (bool, error) {
+	return l.find(sectionName, key).GetBool(sectionName, key)
+}
+
+func (l *Layers) GetBoolWithDefault(sectionName, key string, defValue bool) bool {
+	return l.find(sectionName, key).GetBoolWithDefault(sectionName, key, defValue)
+}
+
+func (l *Layers) GetInt(sectionName, key string) (int, error) {
+	return l.find(sectionName, key).GetInt(sectionName, key)
+}
+
+func (l *Layers) GetIntWithDefault(sectionName, key string, defValue int) int {
+	return l.find(sectionName, key).GetIntWithDefault(sectionName, key, defValue)
+}
+
+func (l *Layers) GetUint(sectionName, key string) (uint, error) {
+	return l.find(sectionName, key).GetUint(sectionName, key)
+}
+
+func (l *Layers) GetUintWithDefault(sectionName, key string, defValue uint) uint {
+	return l.find(sectionName, key).GetUintWithDefault(sectionName, key, defValue)
+}
+
+func (l *Layers) GetInt64(sectionName, key string) (int64, error) {
+	return l.find(sectionName, key).GetInt64(sectionName, key)
+}
+
+func (l *Layers) GetInt64WithDefault(sectionName, key string, defValue int64) int64 {
+	return l.find(sectionName, key).GetInt64WithDefault(sectionName, key, defValue)
+}
+
+func (l *Layers) GetUint64(sectionName, key string) (uint64, error) {
+	return l.find(sectionName, key).GetUint64(sectionName, key)
+}
+
+func (l *Layers) GetUint64WithDefault(sectionName, key string, defValue uint64) uint64 {
+	return l.find(sectionName, key).GetUint64WithDefault(sectionName, key, defValue)
+}
+
+func (l *Layers) GetFloat32(sectionName, key string) (float32, error) {
+	return l.find(sectionName, key).GetFloat32(sectionName, key)
+}
+
+func (l *Layers) GetFloat32WithDefault(sectionName, key string, defValue float32) float32 {
+	return l.find(sectionName, key).GetFloat32WithDefault(sectionName, key, defValue)
+}
+
+func (l *Layers) GetFloat64(sectionName, key string) (float64, error) {
+	return l.find(sectionName, key).GetFloat64(sectionName, key)
+}
+
+func (l *Layers) GetFloat64WithDefault(sectionName, key string, defValue float64) float64 {
+	return l.find(sectionName, key).GetFloat64WithDefault(sectionName, key, defValue)
+}
+
+func (l *Layers) GetStrings(sectionName, key string, sep string) []string {
+	return l.find(sectionName, key).GetStrings(sectionName, key, sep)
+}
+
+func (l *Layers) GetInts(sectionName, key string, sep string) ([]int, error) {
+	return l.find(sectionName, key).GetInts(sectionName, key, sep)
+}
+
+func (l *Layers) GetInt64s(sectionName, key string, sep string) ([]int64, error) {
+	return l.find(sectionName, key).GetInt64s(sectionName, key, sep)
+}
+
+func (l *Layers) GetUint64s(sectionName, key string, sep string) ([]uint64, error) {
+	return l.find(sectionName, key).GetUint64s(sectionName, key, sep)
+}
+
+func (l *Layers) GetFloat64s(sectionName, key string, sep string) ([]float64, error) {
+	return l.find(sectionName, key).GetFloat64s(sectionName, key, sep)
+}
+
+func (l *Layers) GetBools(sectionName, key string, sep string) ([]bool, error) {
+	return l.find(sectionName, key).GetBools(sectionName, key, sep)
+}
+
+// merge all the layers to a new Ini, the keys of a layer override the ones
+// of the layers with lower priority and the origins of the overridden keys
+// are kept. The new Ini uses the settings of the layer with the highest
+// priority, e.g. the resolver and the interpolation
+func (l *Layers) Merge() *Ini {
+	merged := NewIni()
+	if len(l.layers) > 0 {
+		top := l.layers[len(l.layers)-1].ini
+		merged.defaultSectionName = top.defaultSectionName
+		merged.interpolation = top.interpolation
+		merged.resolver = top.resolver
+		merged.lazyExpansion = top.lazyExpansion
+		merged.envOverride = top.envOverride
+	}
+	for _, layer := range l.layers {
+		for _, section := range layer.ini.Sections() {
+			target := merged.NewSection(section.Name)
+			if len(section.comment) > 0 {
+				target.comment = section.comment
+			}
+			if len(section.inlineComment) > 0 {
+				target.inlineComment = section.inlineComment
+			}
+			for _, key := range section.rawKeys() {
+				if k, ok := key.(*normalKey); ok {
+					target.copyKey(k)
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// write the merged layers
+func (l *Layers) Write(writer io.Writer) error {
+	return l.Merge().Write(writer)
+}
+
+// write the merged layers to the file
+func (l *Layers) WriteToFile(fileName string) error {
+	return l.Merge().WriteToFile(fileName)
+}
+
+func (l *Layers) String() string {
+	return l.Merge().String()
+}
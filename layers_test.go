@@ -0,0 +1,49 @@
+package ini
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLayers(t *testing.T) {
+	defaults := Load("[db]\nhost = localhost\nport = 5432\ntimeout = 10\n")
+	system := Load("[db]\nhost = db\n")
+	overrides := Load("[db]\nport = 6432\n[log]\nlevel = debug\n")
+
+	layers := NewLayers()
+	layers.AddLayer("overrides", 100, overrides)
+	layers.AddLayer("defaults", 0, defaults)
+	layers.AddLayer("system", 10, system)
+	if names := strings.Join(layers.LayerNames(), ","); names != "overrides,system,defaults" {
+		t.Errorf("wrong order of the layers: %s", names)
+	}
+	if v, _ := layers.GetValue("db", "host"); v != "db" {
+		t.Errorf("wrong value from the layers: %s", v)
+	}
+	if i, _ := layers.GetInt("db", "port"); i != 6432 {
+		t.Errorf("wrong value from the layers: %d", i)
+	}
+	if i := layers.GetIntWithDefault("db", "timeout", 0); i != 10 {
+		t.Errorf("wrong value from the layers: %d", i)
+	}
+	if _, err := layers.GetValue("db", "user"); err == nil || !strings.Contains(err.Error(), "no such key") {
+		t.Errorf("the missing key should be reported: %v", err)
+	}
+
+	merged := layers.Merge()
+	if merged.String() != "[db]\nhost=db\nport=6432\ntimeout=10\n[log]\nlevel=debug\n" {
+		t.Errorf("wrong merged ini:\n%s", merged.String())
+	}
+
+	if !layers.RemoveLayer("overrides") || layers.RemoveLayer("overrides") {
+		t.Error("fail to remove the layer")
+	}
+	if v, _ := layers.GetValue("db", "port"); v != "5432" || layers.HasSection("log") {
+		t.Errorf("the removed layer should not be used: %s", v)
+	}
+	// the layer with the same name is replaced
+	layers.AddLayer("system", 10, Load("[db]\nhost = db2\n"))
+	if v, _ := layers.GetValue("db", "host"); v != "db2" || len(layers.LayerNames()) != 2 {
+		t.Errorf("the layer should be replaced: %s", v)
+	}
+}
@@ -0,0 +1,160 @@
+package ini
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// the delimiter used if no delimiter is provided
+const defaultListDelimiter = ","
+
+/*
+split the value to a list with the delimiter, the elements are trimmed and
+the empty value is an empty list. An element can include the delimiter by
+escaping it with '\' or putting the element in quotes:
+
+    a, b\, c, "d, e", 'f'
+
+is splitted to: "a", "b, c", "d, e" and "f". A quote not at the start of
+an element is kept as it is, e.g. O'Brien. The value is unescaped when it is
+loaded, so the '\' before the delimiter is written as '\\' in the .ini file
+*/
+func splitList(value string, sep string) []string {
+	if len(sep) <= 0 {
+		sep = defaultListDelimiter
+	}
+	r := make([]string, 0)
+	if len(strings.TrimSpace(value)) <= 0 {
+		return r
+	}
+	elem := bytes.NewBuffer(make([]byte, 0))
+	// the length of the element which can't be trimmed
+	keep := 0
+	var quote byte = 0
+	// the element starts with a quoted run
+	quoted := false
+	n := len(value)
+	for i := 0; i < n; i++ {
+		ch := value[i]
+		switch {
+		case ch == '\\' && i+1 < n:
+			if strings.HasPrefix(value[i+1:], sep) {
+				elem.WriteString(sep)
+				i += len(sep)
+			} else if value[i+1] == '"' || value[i+1] == '\'' || value[i+1] == '\\' {
+				elem.WriteByte(value[i+1])
+				i++
+			} else {
+				elem.WriteByte(ch)
+				continue
+			}
+			keep = elem.Len()
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				elem.WriteByte(ch)
+			}
+			keep = elem.Len()
+		case (ch == '"' || ch == '\'') && elem.Len() == 0 && !quoted:
+			quote = ch
+			quoted = true
+		case strings.HasPrefix(value[i:], sep):
+			r = append(r, trimListElement(elem.String(), keep))
+			elem.Reset()
+			keep = 0
+			quoted = false
+			i += len(sep) - 1
+		case elem.Len() == 0 && unicode.IsSpace(rune(ch)):
+			//skip the leading spaces
+		default:
+			elem.WriteByte(ch)
+		}
+	}
+	return append(r, trimListElement(elem.String(), keep))
+}
+
+// trim the spaces at the end of the element except the first keep chars
+func trimListElement(elem string, keep int) string {
+	return elem[0:keep] + strings.TrimRightFunc(elem[keep:], unicode.IsSpace)
+}
+
+// join the elements to a list with the delimiter, the element is quoted if
+// it can't be splitted back correctly
+func joinList(values []string, sep string) string {
+	if len(sep) <= 0 {
+		sep = defaultListDelimiter
+	}
+	elems := make([]string, 0)
+	for _, value := range values {
+		if len(value) <= 0 || strings.Contains(value, sep) ||
+			strings.ContainsAny(value, "\"'\\") ||
+			strings.TrimSpace(value) != value {
+			value = "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(value) + "\""
+		}
+		elems = append(elems, value)
+	}
+	if strings.TrimSpace(sep) == sep {
+		sep = sep + " "
+	}
+	return strings.Join(elems, sep)
+}
+
+func parseInts(values []string) ([]int, error) {
+	r := make([]int, 0)
+	for _, value := range values {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, i)
+	}
+	return r, nil
+}
+
+func parseInt64s(values []string) ([]int64, error) {
+	r := make([]int64, 0)
+	for _, value := range values {
+		i, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, i)
+	}
+	return r, nil
+}
+
+func parseUint64s(values []string) ([]uint64, error) {
+	r := make([]uint64, 0)
+	for _, value := range values {
+		i, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, i)
+	}
+	return r, nil
+}
+
+func parseFloat64s(values []string) ([]float64, error) {
+	r := make([]float64, 0)
+	for _, value := range values {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, f)
+	}
+	return r, nil
+}
+
+func parseBools(values []string) []bool {
+	r := make([]bool, 0)
+	for _, value := range values {
+		_, ok := trueBoolValue[strings.ToLower(value)]
+		r = append(r, ok)
+	}
+	return r
+}
@@ -0,0 +1,79 @@
+package ini
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitList(t *testing.T) {
+	cases := map[string][]string{
+		"":                           {},
+		"a":                          {"a"},
+		" a , b,c ":                  {"a", "b", "c"},
+		`a\, b, "c, d", ' e ', f\"g`: {"a, b", "c, d", " e ", `f"g`},
+		`c:\temp, ""`:                {`c:\temp`, ""},
+		"a,,b":                       {"a", "", "b"},
+		"O'Brien, Smith":             {"O'Brien", "Smith"},
+		`a "b", c`:                   {`a "b"`, "c"},
+	}
+	for value, expect := range cases {
+		if r := splitList(value, ","); !reflect.DeepEqual(r, expect) {
+			t.Errorf("fail to split %q: %q", value, r)
+		}
+	}
+	if r := splitList("a || b\\||c", "||"); !reflect.DeepEqual(r, []string{"a", "b||c"}) {
+		t.Errorf("fail to split with multi-char delimiter: %q", r)
+	}
+}
+
+func TestListValue(t *testing.T) {
+	data := `[section1]
+hosts = host1, host2\\, host3 , "host4, host5"
+ports = 80|443
+ratios = 0.5, 1
+flags = yes, no, true
+quote = say \"hi\"`
+	ini := Load(data)
+	if v, _ := ini.GetValue("section1", "quote"); v != `say "hi"` {
+		t.Errorf("the unknown escape should be unescaped: %s", v)
+	}
+	if hosts := ini.GetStrings("section1", "hosts", ","); !reflect.DeepEqual(hosts, []string{"host1", "host2, host3", "host4, host5"}) {
+		t.Errorf("wrong hosts: %q", hosts)
+	}
+	if ports, err := ini.GetInts("section1", "ports", "|"); err != nil || !reflect.DeepEqual(ports, []int{80, 443}) {
+		t.Errorf("wrong ports: %v %v", ports, err)
+	}
+	if ratios, err := ini.GetFloat64s("section1", "ratios", ""); err != nil || !reflect.DeepEqual(ratios, []float64{0.5, 1}) {
+		t.Errorf("wrong ratios: %v %v", ratios, err)
+	}
+	if flags, err := ini.GetBools("section1", "flags", ","); err != nil || !reflect.DeepEqual(flags, []bool{true, false, true}) {
+		t.Errorf("wrong flags: %v %v", flags, err)
+	}
+	if _, err := ini.GetInts("section1", "hosts", ","); err == nil {
+		t.Error("invalid int is not reported")
+	}
+	if _, err := ini.GetInts("section1", "not-exist", ","); err == nil {
+		t.Error("missing key is not reported")
+	}
+}
+
+func TestAddSlice(t *testing.T) {
+	values := []string{"a", "b, c", ` d `, `e"f\g`, ""}
+	ini := NewIni()
+	section := ini.NewSection("section1")
+	if err := section.AddSlice("key1", values, ","); err != nil {
+		t.Fatal(err)
+	}
+	section.AddSlice("key2", []int{1, 2, 3}, "|")
+	if section.AddSlice("key3", 1, ",") == nil {
+		t.Error("non-slice is accepted")
+	}
+
+	ini = Load(ini.String())
+	if r := ini.GetStrings("section1", "key1", ","); !reflect.DeepEqual(r, values) {
+		t.Errorf("fail to read back the slice: %q", r)
+	}
+	if r, _ := ini.GetInts("section1", "key2", "|"); !reflect.DeepEqual(r, []int{1, 2, 3}) {
+		t.Errorf("fail to read back the slice: %v", r)
+	}
+}
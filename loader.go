@@ -3,9 +3,9 @@ package ini
 import (
 	"bufio"
 	"bytes"
-	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"strconv"
 	"strings"
@@ -18,6 +18,14 @@ import (
 // and the char before the ';' or '#' must be a space
 //
 func removeComments(value string) string {
+	value, _ = splitInlineComment(value)
+	return strings.TrimSpace(value)
+}
+
+// split the value to the content and the inline comment started with ';' or '#'
+//
+// the comment is empty if no inline comment in the value
+func splitInlineComment(value string) (string, string) {
 	n := len(value)
 	i := 0
 	for ; i < n; i++ {
@@ -25,11 +33,11 @@ func removeComments(value string) string {
 			i++
 		} else if value[i] == ';' || value[i] == '#' {
 			if i > 0 && unicode.IsSpace(rune(value[i-1])) {
-				return strings.TrimSpace(value[0:i])
+				return value[0:i], value[i:]
 			}
 		}
 	}
-	return strings.TrimSpace(value)
+	return value, ""
 }
 
 // check if it is a oct char,e.g. must be char '0' to '7'
@@ -164,26 +172,69 @@ func removeContinuationSuffix(value string) (string, bool) {
 
 type lineReader struct {
 	reader *bufio.Scanner
+	// number of lines read so far
+	lineNo int
+	// keep the original text of the lines read if it is true
+	keepLines bool
+	// the original text of the lines read since last takeLines(), includes
+	// the line terminator
+	lines []string
 }
 
 func newLineReader(reader io.Reader) *lineReader {
-	return &lineReader{reader: bufio.NewScanner(reader)}
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(scanLinesWithTerminator)
+	return &lineReader{reader: scanner}
+}
+
+// same as bufio.ScanLines but the line terminator is kept in the token
+func scanLinesWithTerminator(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[0 : i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
 }
 
+// read the next line without the line terminator
+//
+// return io.EOF if no more line, or the error reported by the underlying reader
 func (lr *lineReader) readLine() (string, error) {
 	if lr.reader.Scan() {
-		return lr.reader.Text(), nil
+		lr.lineNo++
+		line := lr.reader.Text()
+		if lr.keepLines {
+			lr.lines = append(lr.lines, line)
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+	}
+	if err := lr.reader.Err(); err != nil {
+		return "", err
 	}
-	return "", errors.New("No data")
+	return "", io.EOF
+}
 
+// return the original text of the lines read since last call
+func (lr *lineReader) takeLines() []string {
+	lines := lr.lines
+	lr.lines = nil
+	return lines
 }
 
-func readLinesUntilSuffix(lineReader *lineReader, suffix string) string {
+// read lines until a line ends with the suffix
+//
+// return io.EOF if no line ends with the suffix
+func readLinesUntilSuffix(lineReader *lineReader, suffix string) (string, error) {
 	r := ""
 	for {
 		line, err := lineReader.readLine()
 		if err != nil {
-			break
+			return r, err
 		}
 		t := strings.TrimRightFunc(line, unicode.IsSpace)
 		if strings.HasSuffix(t, suffix) {
@@ -193,17 +244,18 @@ func readLinesUntilSuffix(lineReader *lineReader, suffix string) string {
 			r = r + line + "\n"
 		}
 	}
-	return r
+	return r, nil
 }
 
 // if a line enss with char '\', we can read the next line
 //
-func readContinuationLines(lineReader *lineReader) string {
+// return io.EOF if the last line still ends with char '\'
+func readContinuationLines(lineReader *lineReader) (string, error) {
 	r := ""
 	for {
 		line, err := lineReader.readLine()
 		if err != nil {
-			break
+			return r, err
 		}
 		line = strings.TrimRightFunc(line, unicode.IsSpace)
 		if t, continuation := removeContinuationSuffix(line); continuation {
@@ -213,7 +265,7 @@ func readContinuationLines(lineReader *lineReader) string {
 			break
 		}
 	}
-	return r
+	return r, nil
 }
 
 /*
@@ -222,26 +274,46 @@ Load from the sources, the source can be one of:
     - a string includes .ini
     - io.Reader the reader to load the .ini contents
     - byte array incldues .ini content
+    - Source created by FileSource(), OptionalFileSource(), ContentSource(),
+      BytesSource() or ReaderSource()
+
+A string is loaded as a file if the file exists, otherwise as the content.
+Use the Source to tell it explicitly.
+
+All the errors are ignored, use LoadE() if the errors should be reported
 */
 func (ini *Ini) Load(sources ...interface{}) {
 	for _, source := range sources {
-		switch source.(type) {
-		case string:
-			s, _ := source.(string)
-			if _, err := os.Stat(s); err == nil {
-				ini.LoadFile(s)
-			} else {
-				ini.LoadString(s)
-			}
-		case io.Reader:
-			reader, _ := source.(io.Reader)
-			ini.LoadReader(reader)
-		case []byte:
-			b, _ := source.([]byte)
-			ini.LoadBytes(b)
+		ini.loadSource(source)
+	}
+}
+
+// same as Load() but stop at the first source failed to load and return
+// its error. The error is a *ParseError if the content can't be parsed
+func (ini *Ini) LoadE(sources ...interface{}) error {
+	for _, source := range sources {
+		if err := ini.loadSource(source); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
+func (ini *Ini) loadSource(source interface{}) error {
+	switch s := source.(type) {
+	case Source:
+		return s.load(ini)
+	case string:
+		if _, err := os.Stat(s); err == nil {
+			return ini.LoadFileE(s)
+		}
+		return ini.LoadStringE(s)
+	case io.Reader:
+		return ini.LoadReaderE(s)
+	case []byte:
+		return ini.LoadBytesE(s)
+	}
+	return fmt.Errorf("unsupported source type:%T", source)
 }
 
 // return the number of spaces before non-space chars
@@ -279,83 +351,430 @@ func parseSectionName(line string) *string {
 // Explicitly loads .ini from a reader
 //
 func (ini *Ini) LoadReader(reader io.Reader) {
-	lineReader := newLineReader(reader)
-	var curSection *Section = nil
+	ini.LoadReaderE(reader)
+}
+
+// Explicitly loads .ini from a reader and return the error if fail to
+// read from the reader or the content can't be parsed
+func (ini *Ini) LoadReaderE(reader io.Reader) error {
+	return ini.loadReader(reader, "")
+}
+
+// loader parses the .ini content of one source into the Ini
+type loader struct {
+	ini        *Ini
+	source     string
+	lineReader *lineReader
+	// problems found in the content
+	errs ParseErrors
+	// the text and the original lines of the comment lines not followed
+	// by a empty line
+	comments     []string
+	commentLines []string
+	// the section the keys are added to, it is not in the ini if
+	// ignoreSection is true
+	curSection    *Section
+	ignoreSection bool
+	// the last loaded key and its node, the indented lines after it
+	// are the values of the key
+	curKey     *normalKey
+	curKeyNode *docNode
+	// true if the last key is ignored
+	skipKey bool
+	// the file system to load the included files and the absolute path
+	// of the file loaded, the path is empty if it is not a file
+	fsys fs.FS
+	path string
+	// the loader of the including content and the line of the include
+	// directive if the content is included, otherwise nil
+	parent      *loader
+	includeLine int
+}
+
+// record a problem found in the content
+func (l *loader) addError(line int, column int, text string, msg string) {
+	pe := newParseError(l.source, line, column, text, msg)
+	pe.Includes = l.includeChain()
+	l.errs = append(l.errs, pe)
+}
+
+// record a problem found only in strict mode
+func (l *loader) addStrictError(line int, column int, text string, msg string) {
+	if l.ini.strict {
+		l.addError(line, column, text, msg)
+	}
+}
+
+// return the problems found in the content
+//
+// all the problems are returned in strict mode, otherwise only the first one
+func (l *loader) err() error {
+	if len(l.errs) <= 0 {
+		return nil
+	}
+	if l.ini.strict {
+		return l.errs
+	}
+	return l.errs[0]
+}
+
+// load the .ini from reader, the source is the name of the reader used
+// in the error message
+//
+// all the lines are processed even if some of them can't be parsed
+func (ini *Ini) loadReader(reader io.Reader, source string) error {
+	return newLoader(ini, reader, source).load()
+}
+
+func newLoader(ini *Ini, reader io.Reader, source string) *loader {
+	return &loader{ini: ini, source: source, lineReader: newLineReader(reader), fsys: osFS{}}
+}
+
+func (l *loader) load() error {
+	ini := l.ini
+	lineReader := l.lineReader
+	// the included content is not written back in preserve format mode
+	lineReader.keepLines = ini.preserveFormat && l.parent == nil
 	keyIndent := -1
-	prevKey := ""
 	for {
 		line, err := lineReader.readLine()
-		if err != nil {
+		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			return l.readError(err)
+		}
 
 		//if this line is value of the key
-		if keyIndent >= 0 && getIndent(line) > keyIndent && curSection != nil && prevKey != "" {
-			v := curSection.GetValueWithDefault(prevKey, "")
-			v = fmt.Sprintf("%s\n%s", v, fromEscape(removeComments(line)))
-			curSection.Add(prevKey, v )
-
+		if keyIndent >= 0 && getIndent(line) > keyIndent && (l.curKey != nil || l.skipKey) {
+			if l.curKey != nil {
+				l.curKey.setValue(fmt.Sprintf("%s\n%s", l.curKey.value, fromEscape(removeComments(line))))
+			}
+			if l.curKeyNode != nil {
+				l.curKeyNode.lines = append(l.curKeyNode.lines, lineReader.takeLines()...)
+				l.curKeyNode.values = l.curKey.rawValues()
+				l.curKeyNode.suffix = ""
+			} else {
+				l.addTrivia()
+			}
 			continue
 		}
+		l.curKey = nil
+		l.curKeyNode = nil
+		l.skipKey = false
 
 		//empty line or comments line
 		if isCommentLine(line) {
+			l.addComment(line)
+			continue
+		}
+		lineNo := lineReader.lineNo
+		if path, dir, ok := parseIncludeDirective(line); ok && ini.includeFiles {
+			l.comments = nil
+			l.addTrivia()
+			l.include(path, dir, lineNo, line)
+			keyIndent = -1
 			continue
 		}
 		//if it is a section
-		sectionName := parseSectionName( line )
+		sectionName := parseSectionName(line)
+		inlineComment := ""
+		if sectionName == nil {
+			//the section may have inline comment
+			content, comment := splitInlineComment(line)
+			if sectionName = parseSectionName(content); sectionName != nil {
+				inlineComment = commentText(comment)
+			}
+		}
 		if sectionName != nil {
-			curSection = ini.NewSection(*sectionName)
-			// reset the previous key and the key indent
-			prevKey = ""
+			l.loadSection(*sectionName, inlineComment, lineNo, line)
+			// reset the key indent
 			keyIndent = -1
 			continue
 		}
+		if ini.strict && strings.HasPrefix(strings.TrimSpace(line), "[") {
+			l.addError(lineNo, getIndent(line)+1, line, "section header is not closed with ']'")
+			l.addTrivia()
+			continue
+		}
 		//key&value is separated with = or :
 		pos := strings.IndexAny(line, "=:")
-		if pos != -1 {
-			keyIndent = getIndent(line)
-			key := strings.TrimSpace(line[0:pos])
-			prevKey = key
-			value := strings.TrimLeftFunc(line[pos+1:], unicode.IsSpace)
-			//if it is a multiline indicator """
-			if strings.HasPrefix(value, "\"\"\"") {
-				t := strings.TrimRightFunc(value, unicode.IsSpace)
-				//if the end multiline indicator is found
-				if strings.HasSuffix(t, "\"\"\"") {
-					value = t[3 : len(t)-3]
-				} else { //read lines until end multiline indicator is found
-					value = value[3:] + "\n" + readLinesUntilSuffix(lineReader, "\"\"\"")
-				}
-			} else {
-				value = strings.TrimRightFunc(value, unicode.IsSpace)
-				//if is it a continuation line
-				if t, continuation := removeContinuationSuffix(value); continuation {
-					value = t + readContinuationLines(lineReader)
+		if pos == -1 {
+			l.addError(lineNo, getIndent(line)+1, line, "no key/value separator '=' or ':' found")
+			l.addTrivia()
+			continue
+		}
+		keyIndent = getIndent(line)
+		key := strings.TrimSpace(line[0:pos])
+		value := strings.TrimLeftFunc(line[pos+1:], unicode.IsSpace)
+		prefix := line[0 : len(line)-len(value)]
+		suffix := ""
+		//if it is a multiline indicator """
+		if strings.HasPrefix(value, "\"\"\"") {
+			t := strings.TrimRightFunc(value, unicode.IsSpace)
+			//if the end multiline indicator is found
+			if len(t) >= 6 && strings.HasSuffix(t, "\"\"\"") {
+				value = t[3 : len(t)-3]
+			} else { //read lines until end multiline indicator is found
+				lines, err := readLinesUntilSuffix(lineReader, "\"\"\"")
+				if err == io.EOF {
+					l.addStrictError(lineNo, pos+1, line, "multi-line value is not terminated with \"\"\"")
+				} else if err != nil {
+					return l.readError(err)
 				}
+				value = value[3:] + "\n" + lines
 			}
-
-			if len(key) > 0 {
-				if curSection == nil && len(ini.defaultSectionName) > 0 {
-					curSection = ini.NewSection(ini.defaultSectionName)
-				}
-				if curSection != nil {
-					//remove the comments and convert escape char to real
-					curSection.Add(key, strings.TrimSpace(fromEscape(removeComments(value))))
+		} else {
+			content, _ := splitInlineComment(value)
+			suffix = value[len(strings.TrimRightFunc(content, unicode.IsSpace)):]
+			value = strings.TrimRightFunc(value, unicode.IsSpace)
+			//if is it a continuation line
+			if t, continuation := removeContinuationSuffix(value); continuation {
+				lines, err := readContinuationLines(lineReader)
+				if err == io.EOF {
+					l.addStrictError(lineNo, pos+1, line, "no line follows the continuation char '\\'")
+				} else if err != nil {
+					return l.readError(err)
 				}
+				value = t + lines
+				suffix = ""
 			}
 		}
+
+		if len(key) <= 0 {
+			l.addStrictError(lineNo, keyIndent+1, line, "empty key name")
+			l.addTrivia()
+			continue
+		}
+		if l.curSection == nil && len(ini.defaultSectionName) > 0 {
+			l.curSection = ini.NewSection(ini.defaultSectionName)
+		}
+		if l.curSection == nil {
+			l.addTrivia()
+			continue
+		}
+		_, comment := splitInlineComment(value)
+		//remove the comments and convert escape char to real
+		value = strings.TrimSpace(fromEscape(removeComments(value)))
+		if key == "include" && ini.includeFiles {
+			l.comments = nil
+			l.addTrivia()
+			l.include(value, false, lineNo, line)
+			keyIndent = -1
+			continue
+		}
+		if _, err := ini.expandEnv(value); err != nil && !ini.lazyExpansion {
+			// the error is returned when the value is read in lazy mode
+			l.addError(lineNo, len(prefix)+1, line, "fail to expand the environment variables")
+			l.errs[len(l.errs)-1].Err = err
+		}
+		k := l.loadKey(key, value, lineNo, line)
+		if k == nil {
+			l.skipKey = true
+			l.addTrivia()
+			continue
+		}
+		if c := l.comment(); len(c) > 0 {
+			k.comment = c
+		}
+		if c := commentText(comment); len(c) > 0 {
+			k.inlineComment = c
+		}
+		l.curKey = k
+		if l.ignoreSection {
+			l.addTrivia()
+		} else {
+			l.curKeyNode = l.addKeyNode(k, l.curSection, prefix, suffix)
+		}
 	}
+	l.addTrivia()
+	return l.err()
+}
+
+// switch to the section defined in the line by the duplicate section policy
+func (l *loader) loadSection(name string, inlineComment string, lineNo int, line string) {
+	ini := l.ini
+	old, exists := ini.sections[name]
+	l.ignoreSection = false
+	if !exists || ini.sectionPolicy == DuplicateMerge {
+		l.curSection = ini.NewSection(name)
+	} else if ini.sectionPolicy == DuplicateLastWins {
+		l.curSection = NewSection(name)
+		ini.AddSection(l.curSection)
+	} else {
+		if ini.sectionPolicy == DuplicateError {
+			msg := fmt.Sprintf("duplicate section %s, it is defined at %s", name, definedAt(old.source, old.line))
+			l.addError(lineNo, getIndent(line)+1, line, msg)
+		}
+		// the content of the section is ignored
+		l.curSection = NewSection(name)
+		l.ignoreSection = true
+	}
+	section := l.curSection
+	if section.line <= 0 {
+		section.source = l.source
+		section.line = lineNo
+	}
+	// the comments of a merged section are the ones of its first header
+	comment := l.comment()
+	if section != old {
+		if len(comment) > 0 {
+			section.comment = comment
+		}
+		if len(inlineComment) > 0 {
+			section.inlineComment = inlineComment
+		}
+	}
+	if l.ignoreSection {
+		l.addTrivia()
+		return
+	}
+	l.addNode(&docNode{kind: sectionNode,
+		section:       section,
+		sectionName:   section.Name,
+		comment:       section.comment,
+		inlineComment: section.inlineComment})
+}
+
+// add the key to the current section by the duplicate key policy
+//
+// return the key or nil if the key is ignored
+func (l *loader) loadKey(key string, value string, lineNo int, line string) *normalKey {
+	ini := l.ini
+	section := l.curSection
+	old, exists := section.keyValues[key].(*normalKey)
+	if !exists {
+		section.Add(key, value)
+	} else {
+		switch ini.keyPolicy {
+		case DuplicateMerge:
+			section.AddValue(key, value)
+			return old
+		case DuplicateLastWins:
+			section.Add(key, value)
+		case DuplicateError:
+			msg := fmt.Sprintf("duplicate key %s in section %s, it is defined at %s", key, section.Name, definedAt(old.source, old.line))
+			l.addError(lineNo, getIndent(line)+1, line, msg)
+			return nil
+		default:
+			return nil
+		}
+	}
+	k, _ := section.keyValues[key].(*normalKey)
+	k.source = l.source
+	k.line = lineNo
+	return k
+}
+
+// return the position where the section or key is defined
+func definedAt(source string, line int) string {
+	if line <= 0 && len(source) > 0 {
+		return source
+	}
+	if line <= 0 {
+		return "unknown position"
+	}
+	if len(source) > 0 {
+		return fmt.Sprintf("%s:%d", source, line)
+	}
+	return fmt.Sprintf("line %d", line)
+}
+
+// add the lines read as a node of the document in preserve format mode
+//
+// the comment lines just before the node are kept in the node
+func (l *loader) addNode(node *docNode) {
+	lines := l.lineReader.takeLines()
+	if l.ini.preserveFormat && len(lines) > 0 {
+		node.leading = l.commentLines
+		node.lines = lines
+		l.ini.doc = append(l.ini.doc, node)
+	}
+	l.comments = nil
+	l.commentLines = nil
+}
+
+// add the lines read as empty lines or unknown lines
+func (l *loader) addTrivia() {
+	if len(l.commentLines) > 0 {
+		l.ini.doc = append(l.ini.doc, &docNode{kind: triviaNode, lines: l.commentLines})
+	}
+	l.commentLines = nil
+	l.addNode(&docNode{kind: triviaNode})
+}
+
+// add a comment line or an empty line. The comment lines just before a section
+// or a key without empty line between them are the comment of the section or key
+func (l *loader) addComment(line string) {
+	if len(strings.TrimSpace(line)) <= 0 {
+		l.addTrivia()
+		return
+	}
+	l.comments = append(l.comments, commentText(line))
+	if l.ini.preserveFormat {
+		l.commentLines = append(l.commentLines, l.lineReader.takeLines()...)
+	}
+}
+
+// return the comment lines just before the current line
+func (l *loader) comment() string {
+	return strings.Join(l.comments, "\n")
+}
+
+// add the lines read as the key and bind the key to the node
+func (l *loader) addKeyNode(k *normalKey, section *Section, prefix string, suffix string) *docNode {
+	if !l.ini.preserveFormat {
+		l.comments = nil
+		return nil
+	}
+	node := &docNode{kind: keyNode,
+		section:       section,
+		sectionName:   section.Name,
+		key:           k.name,
+		values:        k.rawValues(),
+		multiValue:    l.ini.keyPolicy == DuplicateMerge,
+		comment:       k.comment,
+		inlineComment: k.inlineComment,
+		prefix:        prefix,
+		suffix:        suffix}
+	l.addNode(node)
+	k.node = node
+	return node
+}
+
+// return the text of a comment without the leading ';' or '#' and
+// the first space after it
+func commentText(comment string) string {
+	comment = strings.TrimSpace(comment)
+	if len(comment) <= 0 {
+		return ""
+	}
+	comment = comment[1:]
+	if strings.HasPrefix(comment, " ") {
+		comment = comment[1:]
+	}
+	return comment
+}
+
+// create the error for the failure of reading next line
+// record the error of reading the next line and return it
+func (l *loader) readError(err error) error {
+	pe := &ParseError{Source: l.source, Line: l.lineReader.lineNo + 1, Column: 1, Msg: "fail to read line", Err: err}
+	pe.Includes = l.includeChain()
+	l.errs = append(l.errs, pe)
+	return pe
 }
 
 // Load ini file from file named fileName
 //
 func (ini *Ini) LoadFile(fileName string) {
-	f, err := os.Open(fileName)
-	if err == nil {
-		defer f.Close()
-		ini.Load(f)
-	}
+	ini.LoadFileE(fileName)
+}
+
+// Load ini file from file named fileName and return the error if the
+// file can't be read or parsed
+func (ini *Ini) LoadFileE(fileName string) error {
+	return ini.loadFile(osFS{}, fileName)
 }
 
 var defaultSectionName string = "default"
@@ -367,12 +786,24 @@ func SetDefaultSectionName(defSectionName string) {
 // load ini from the content which contains the .ini formated string
 //
 func (ini *Ini) LoadString(content string) {
-	ini.Load(bytes.NewBufferString(content))
+	ini.LoadStringE(content)
+}
+
+// load ini from the content which contains the .ini formated string and
+// return the error if the content can't be parsed
+func (ini *Ini) LoadStringE(content string) error {
+	return ini.loadReader(bytes.NewBufferString(content), "")
 }
 
 // load .ini from a byte array which contains the .ini formated content
 func (ini *Ini) LoadBytes(content []byte) {
-	ini.Load(bytes.NewBuffer(content))
+	ini.LoadBytesE(content)
+}
+
+// load .ini from a byte array which contains the .ini formated content and
+// return the error if the content can't be parsed
+func (ini *Ini) LoadBytesE(content []byte) error {
+	return ini.loadReader(bytes.NewBuffer(content), "")
 }
 
 /*
@@ -395,3 +826,32 @@ func Load(sources ...interface{}) *Ini {
 	}
 	return ini
 }
+
+/*
+Same as Load() but return the error instead of ignoring it, so a missing
+file or a broken content can be detected:
+
+    ini, err := ini.LoadE( "./my.ini", "./my2.ini" )
+    if err != nil {
+        //the error is a *ParseError if the content can't be parsed
+    }
+*/
+func LoadE(sources ...interface{}) (*Ini, error) {
+	ini := NewIni()
+	ini.SetDefaultSectionName(defaultSectionName)
+	if err := ini.LoadE(sources...); err != nil {
+		return nil, err
+	}
+	return ini, nil
+}
+
+// load the .ini from the file and return the error if the file can't be
+// read or parsed
+func ParseFile(fileName string) (*Ini, error) {
+	ini := NewIni()
+	ini.SetDefaultSectionName(defaultSectionName)
+	if err := ini.LoadFileE(fileName); err != nil {
+		return nil, err
+	}
+	return ini, nil
+}
@@ -0,0 +1,51 @@
+package ini
+
+import (
+	"os"
+)
+
+// Resolver looks up the value of a variable in the values of keys, e.g.
+// ${HOME} or ${PORT:-80}
+type Resolver interface {
+	// return the value of the variable and true if it is found
+	Lookup(name string) (string, bool)
+}
+
+// EnvResolver looks up the variables in the environment of the process, it
+// is the default resolver
+type EnvResolver struct {
+}
+
+func (EnvResolver) Lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// MapResolver looks up the variables in the map
+type MapResolver map[string]string
+
+func (r MapResolver) Lookup(name string) (string, bool) {
+	value, ok := r[name]
+	return value, ok
+}
+
+// ChainResolver looks up the variables in the resolvers one by one and
+// returns the first found value
+type ChainResolver []Resolver
+
+func (r ChainResolver) Lookup(name string) (string, bool) {
+	for _, resolver := range r {
+		if value, ok := resolver.Lookup(name); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// NoopResolver finds no variable, and the variables in the values are not
+// expanded at all but kept as they are if it is the resolver of the ini
+type NoopResolver struct {
+}
+
+func (NoopResolver) Lookup(name string) (string, bool) {
+	return "", false
+}
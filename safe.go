@@ -0,0 +1,252 @@
+package ini
+
+import (
+	"io"
+	"sync"
+)
+
+// SafeIni guards an Ini with a RW lock, so the values can be read by many
+// goroutines while the ini is reloaded or modified by another goroutine.
+// The Section and Key got from the Ini are not guarded, so they should only
+// be used in the function passed to Read() or Update()
+type SafeIni struct {
+	mu  sync.RWMutex
+	ini *Ini
+}
+
+// create a SafeIni guarding the ini, a new Ini is created if it is nil. The
+// ini should not be used without the SafeIni after that
+func NewSafeIni(ini *Ini) *SafeIni {
+	if ini == nil {
+		ini = NewIni()
+	}
+	return &SafeIni{ini: ini}
+}
+
+// call the function with the ini for reading, the ini must not be modified
+// in it
+func (s *SafeIni) Read(fn func(ini *Ini)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.ini)
+}
+
+// call the function with the ini for modifying, the readers are blocked
+// until it returns
+func (s *SafeIni) Update(fn func(ini *Ini) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.ini)
+}
+
+// load the sources into the ini like Ini.Load(), the readers are blocked
+// while loading
+func (s *SafeIni) Load(sources ...interface{}) {
+	s.LoadE(sources...)
+}
+
+// Same as Load() but return the error
+func (s *SafeIni) LoadE(sources ...interface{}) error {
+	return s.Update(func(ini *Ini) error {
+		return ini.LoadE(sources...)
+	})
+}
+
+// load the sources into a new Ini with the settings of the current one, and
+// replace the current one with it if there is no error. The readers are not
+// blocked while loading and never see a partially loaded ini, and the
+// current one is kept if the loading fails
+func (s *SafeIni) Reload(sources ...interface{}) error {
+	s.mu.RLock()
+	ini := s.ini.emptyCopy()
+	s.mu.RUnlock()
+	if err := ini.LoadE(sources...); err != nil {
+		return err
+	}
+	s.Replace(ini)
+	return nil
+}
+
+// replace the guarded ini, the ini should not be used without the SafeIni
+// after that
+func (s *SafeIni) Replace(ini *Ini) {
+	s.swap(ini)
+}
+
+// replace the guarded ini and return the replaced one
+func (s *SafeIni) swap(ini *Ini) *Ini {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.ini
+	s.ini = ini
+	return old
+}
+
+func (s *SafeIni) HasSection(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.HasSection(name)
+}
+
+func (s *SafeIni) HasKey(sectionName, key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.HasKey(sectionName, key)
+}
+
+func (s *SafeIni) GetValue(sectionName, key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetValue(sectionName, key)
+}
+
+func (s *SafeIni) GetValueWithDefault(sectionName, key string, defValue string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetValueWithDefault(sectionName, key, defValue)
+}
+
+func (s *SafeIni) GetBool(sectionName, key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetBool(sectionName, key)
+}
+
+func (s *SafeIni) GetBoolWithDefault(sectionName, key string, defValue bool) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetBoolWithDefault(sectionName, key, defValue)
+}
+
+func (s *SafeIni) GetInt(sectionName, key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetInt(sectionName, key)
+}
+
+func (s *SafeIni) GetIntWithDefault(sectionName, key string, defValue int) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetIntWithDefault(sectionName, key, defValue)
+}
+
+func (s *SafeIni) GetUint(sectionName, key string) (uint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetUint(sectionName, key)
+}
+
+func (s *SafeIni) GetUintWithDefault(sectionName, key string, defValue uint) uint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetUintWithDefault(sectionName, key, defValue)
+}
+
+func (s *SafeIni) GetInt64(sectionName, key string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetInt64(sectionName, key)
+}
+
+func (s *SafeIni) GetInt64WithDefault(sectionName, key string, defValue int64) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetInt64WithDefault(sectionName, key, defValue)
+}
+
+func (s *SafeIni) GetUint64(sectionName, key string) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetUint64(sectionName, key)
+}
+
+func (s *SafeIni) GetUint64WithDefault(sectionName, key string, defValue uint64) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetUint64WithDefault(sectionName, key, defValue)
+}
+
+func (s *SafeIni) GetFloat32(sectionName, key string) (float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetFloat32(sectionName, key)
+}
+
+func (s *SafeIni) GetFloat32WithDefault(sectionName, key string, defValue float32) float32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetFloat32WithDefault(sectionName, key, defValue)
+}
+
+func (s *SafeIni) GetFloat64(sectionName, key string) (float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetFloat64(sectionName, key)
+}
+
+func (s *SafeIni) GetFloat64WithDefault(sectionName, key string, defValue float64) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetFloat64WithDefault(sectionName, key, defValue)
+}
+
+func (s *SafeIni) GetStrings(sectionName, key string, sep string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetStrings(sectionName, key, sep)
+}
+
+func (s *SafeIni) GetInts(sectionName, key string, sep string) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetInts(sectionName, key, sep)
+}
+
+func (s *SafeIni) GetInt64s(sectionName, key string, sep string) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetInt64s(sectionName, key, sep)
+}
+
+func (s *SafeIni) GetUint64s(sectionName, key string, sep string) ([]uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetUint64s(sectionName, key, sep)
+}
+
+func (s *SafeIni) GetFloat64s(sectionName, key string, sep string) ([]float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetFloat64s(sectionName, key, sep)
+}
+
+func (s *SafeIni) GetBools(sectionName, key string, sep string) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.GetBools(sectionName, key, sep)
+}
+
+// map the ini to the struct like Ini.MapTo()
+func (s *SafeIni) MapTo(v interface{}) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.MapTo(v)
+}
+
+func (s *SafeIni) Write(writer io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.Write(writer)
+}
+
+func (s *SafeIni) WriteToFile(fileName string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.WriteToFile(fileName)
+}
+
+func (s *SafeIni) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ini.String()
+}
@@ -0,0 +1,74 @@
+package ini
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// run with -race to check the reads are not racing with the loads
+func TestSafeIni(t *testing.T) {
+	s := NewSafeIni(nil)
+	s.Load("[db]\nport = 0\n")
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := s.GetInt("db", "port"); err != nil {
+					t.Error(err)
+					return
+				}
+				s.GetStrings("db", "hosts", ",")
+				s.Read(func(ini *Ini) {
+					for _, section := range ini.Sections() {
+						section.Keys()
+					}
+				})
+			}
+		}()
+	}
+	for i := 1; i <= 100; i++ {
+		content := fmt.Sprintf("[db]\nport = %d\nhosts = a,b\n[s%d]\nkey = value\n", i, i)
+		if i%2 == 0 {
+			s.Load(content)
+		} else if err := s.Reload(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if v, _ := s.GetInt("db", "port"); v != 100 {
+		t.Errorf("wrong value after the loads: %d", v)
+	}
+	// the sections loaded before the last reload are dropped
+	if s.HasSection("s98") || !s.HasSection("s100") || !s.HasSection("s99") {
+		t.Errorf("wrong sections after the reload: %s", s.String())
+	}
+	if err := s.Reload("[db]\nport"); err == nil {
+		t.Errorf("the reload should fail")
+	}
+	if v, _ := s.GetInt("db", "port"); v != 100 {
+		t.Errorf("the ini should be kept if the reload fails: %d", v)
+	}
+	err := s.Update(func(ini *Ini) error {
+		ini.NewSection("db").Add("port", "200")
+		return nil
+	})
+	if v, _ := s.GetInt("db", "port"); err != nil || v != 200 {
+		t.Errorf("wrong value after the update: %d, %v", v, err)
+	}
+	if !strings.Contains(s.String(), "port=200") {
+		t.Errorf("wrong content: %s", s.String())
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"reflect"
+	"strings"
 )
 
 // manages all the key/value defined in the .ini file format
@@ -12,6 +14,17 @@ type Section struct {
 	Name string
 	//key values
 	keyValues map[string]Key
+	//key names in the order they are added
+	keyNames []string
+	//comment lines before the section header
+	comment string
+	//inline comment after the section header
+	inlineComment string
+	//where the section is loaded from, the line is 0 if it is not loaded
+	source string
+	line   int
+	//the ini the section is added to, may be nil
+	ini *Ini
 }
 
 // construct a new section with section name
@@ -21,25 +34,124 @@ func NewSection(name string) *Section {
 }
 
 // add key/value to the section and overwrite the old one
+//
+// the overwritten key keeps its position in the section
 func (section *Section) Add(key, value string) {
-	section.keyValues[key] = newNormalKey(key, value)
+	k := newNormalKey(section, key, value)
+	if old, ok := section.keyValues[key]; !ok {
+		section.keyNames = append(section.keyNames, key)
+	} else if oldKey, ok := old.(*normalKey); ok {
+		k.node = oldKey.node
+		k.comment = oldKey.comment
+		k.inlineComment = oldKey.inlineComment
+		k.overridden = oldKey.overridden
+		if oldKey.line > 0 {
+			k.overridden = append([]Origin{{Source: oldKey.source, Line: oldKey.line}}, oldKey.overridden...)
+		}
+	}
+	section.keyValues[key] = k
+}
+
+// add a copy of the key from another section, the copy overrides the key
+// with the same name and keeps the origins of the overridden key
+func (section *Section) copyKey(k *normalKey) {
+	c := *k
+	c.section = section
+	c.node = nil
+	c.values = append([]string(nil), k.values...)
+	c.expanded = append([]string(nil), k.expanded...)
+	c.expandErrs = append([]error(nil), k.expandErrs...)
+	c.overridden = append([]Origin(nil), k.overridden...)
+	if old, ok := section.keyValues[k.name].(*normalKey); !ok {
+		section.keyNames = append(section.keyNames, k.name)
+	} else {
+		if old.line > 0 {
+			c.overridden = append(c.overridden, Origin{Source: old.source, Line: old.line})
+		}
+		c.overridden = append(c.overridden, old.overridden...)
+	}
+	section.keyValues[k.name] = &c
+}
+
+// get the comment lines before the section header, the leading ';' or '#'
+// is removed from each line and the lines are joined with '\n'
+func (section *Section) Comment() string {
+	return section.comment
+}
+
+// set the comment lines before the section header, multiple lines should be
+// separated with '\n'
+func (section *Section) SetComment(comment string) {
+	section.comment = comment
+}
+
+// get the inline comment after the section header
+func (section *Section) InlineComment() string {
+	return section.inlineComment
+}
+
+// set the inline comment after the section header
+func (section *Section) SetInlineComment(comment string) {
+	section.inlineComment = comment
+}
+
+// add the elements of a slice or array as the value of key, the elements
+// are joined with the delimiter sep and can be read back by GetStrings()
+// and the other GetXXXs() methods with the same delimiter
+func (section *Section) AddSlice(key string, values interface{}, sep string) error {
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("a slice or array is required but it is %T", values)
+	}
+	section.Add(key, joinList(sliceValues(rv), sep))
+	return nil
+}
+
+// add a value to the key, the key has multiple values if it exists.
+// All the values can be got by Key.Values() and each value is written
+// in a line
+func (section *Section) AddValue(key, value string) {
+	if k, ok := section.keyValues[key].(*normalKey); ok {
+		k.addValue(value)
+	} else {
+		section.Add(key, value)
+	}
 }
 
-// check if the key is in the section
+// check if the key is in the section, or in the default section of the
+// python dialects
 //
 // return true if the section contains the key
 func (section *Section) HasKey(key string) bool {
-	_, ok := section.keyValues[key]
+	if _, ok := section.keyValues[key]; ok {
+		return true
+	}
+	_, ok := section.findKey(key)
 	return ok
 }
 
 // Get all the keys in the section
 //
-// return: all keys in the section
+// return: all keys in the section in the order they are added or loaded,
+// the keys are overridden by the environment variables as Key()
 func (section *Section) Keys() []Key {
 	r := make([]Key, 0)
-	for _, v := range section.keyValues {
-		r = append(r, v)
+	for _, name := range section.keyNames {
+		if k, ok := section.lookupKey(name); ok {
+			r = append(r, k)
+		} else {
+			r = append(r, section.keyValues[name])
+		}
+	}
+	return r
+}
+
+// get all the keys in the section without the environment overrides, the
+// keys written are got by it
+func (section *Section) rawKeys() []Key {
+	r := make([]Key, 0)
+	for _, name := range section.keyNames {
+		r = append(r, section.keyValues[name])
 	}
 	return r
 }
@@ -49,12 +161,76 @@ func (section *Section) Keys() []Key {
 // This method can be called even if the key is not in the
 // section.
 func (section *Section) Key(key string) Key {
+	if k, ok := section.findKey(key); ok {
+		return k
+	}
 	if v, ok := section.keyValues[key]; ok {
 		return v
 	}
 	return newNonExistKey(key)
 }
 
+// find the key as python configparser if a python dialect is set: the key
+// names are case insensitive and the key not found is looked up in the
+// default section, the key found there is a copy in this section so its
+// references are expanded in this section
+func (section *Section) findKey(key string) (*normalKey, bool) {
+	if k, ok := section.lookupKey(key); ok || section.ini == nil {
+		return k, ok
+	}
+	dialect, ok := section.ini.interpolation.(pythonDialect)
+	if !ok {
+		return nil, false
+	}
+	if k, ok := section.foldKey(key); ok {
+		return k, true
+	}
+	defSection, ok := section.ini.sections[dialect.defaultSection()]
+	if !ok || defSection == section {
+		return nil, false
+	}
+	k, ok := defSection.foldKey(key)
+	if !ok {
+		return nil, false
+	}
+	c := *k
+	c.section = section
+	return &c, true
+}
+
+// find the key with the case insensitive name
+func (section *Section) foldKey(key string) (*normalKey, bool) {
+	if k, ok := section.lookupKey(key); ok {
+		return k, true
+	}
+	for _, name := range section.keyNames {
+		if strings.ToLower(name) == strings.ToLower(key) {
+			return section.lookupKey(name)
+		}
+	}
+	return nil, false
+}
+
+// get the key and override it with the environment variable if the
+// environment override is enabled
+func (section *Section) lookupKey(key string) (*normalKey, bool) {
+	k, ok := section.keyValues[key].(*normalKey)
+	if !ok || k.fromFlag || section.ini == nil || section.ini.envOverride == nil {
+		return k, ok
+	}
+	name, value, found := section.ini.envOverride.lookup(section.Name, key, section.ini.defaultSectionName)
+	if !found {
+		return k, true
+	}
+	// the value is a literal, it is not expanded or interpolated
+	override := &normalKey{name: key, value: value, section: section, expanded: []string{value}, literal: true}
+	override.source = "$" + name
+	override.comment = k.comment
+	override.inlineComment = k.inlineComment
+	override.overridden = append([]Origin{{Source: k.source, Line: k.line}}, k.overridden...)
+	return override, true
+}
+
 // Get value of key as string
 func (section *Section) GetValue(key string) (string, error) {
 	return section.Key(key).Value()
@@ -148,6 +324,37 @@ func (section *Section) GetFloat64WithDefault(key string, defValue float64) floa
 	return section.Key(key).Float64WithDefault(defValue)
 }
 
+// Get the value of the key as a list splitted by the delimiter sep, see
+// Key.Strings() for the format of the list
+func (section *Section) GetStrings(key string, sep string) []string {
+	return section.Key(key).Strings(sep)
+}
+
+// Get the value of the key as a list of int
+func (section *Section) GetInts(key string, sep string) ([]int, error) {
+	return section.Key(key).Ints(sep)
+}
+
+// Get the value of the key as a list of int64
+func (section *Section) GetInt64s(key string, sep string) ([]int64, error) {
+	return section.Key(key).Int64s(sep)
+}
+
+// Get the value of the key as a list of uint64
+func (section *Section) GetUint64s(key string, sep string) ([]uint64, error) {
+	return section.Key(key).Uint64s(sep)
+}
+
+// Get the value of the key as a list of float64
+func (section *Section) GetFloat64s(key string, sep string) ([]float64, error) {
+	return section.Key(key).Float64s(sep)
+}
+
+// Get the value of the key as a list of bool
+func (section *Section) GetBools(key string, sep string) ([]bool, error) {
+	return section.Key(key).Bools(sep)
+}
+
 // convert the section content to the .ini section format, so the section content will
 // be converted to following format:
 //
@@ -162,16 +369,67 @@ func (section *Section) String() string {
 }
 
 // write the section content to the writer with .ini section format.
+//
+// the comments are written in following format:
+//
+//  # comment of section
+//  [sectionx] # inline comment of section
+//  # comment of key1
+//  key1 = value1 # inline comment of key1
 func (section *Section) Write(writer io.Writer) error {
-	_, err := fmt.Fprintf(writer, "[%s]\n", section.Name)
+	err := writeComment(writer, section.comment, "\n")
 	if err != nil {
 		return err
 	}
-	for _, v := range section.keyValues {
-		_, err = fmt.Fprintf(writer, "%s\n", v.String())
+	header := fmt.Sprintf("[%s]", section.Name)
+	_, err = fmt.Fprintf(writer, "%s\n", appendInlineComment(header, section.inlineComment))
+	if err != nil {
+		return err
+	}
+	for _, v := range section.rawKeys() {
+		err = writeKey(writer, v)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// write the key with its comments
+func writeKey(writer io.Writer, key Key) error {
+	err := writeComment(writer, key.Comment(), "\n")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "%s\n", appendInlineComment(key.String(), key.InlineComment()))
+	return err
+}
+
+// write each line of the comment started with "# " and ended with the terminator
+func writeComment(writer io.Writer, comment string, terminator string) error {
+	if len(comment) <= 0 {
+		return nil
+	}
+	if len(terminator) <= 0 {
+		terminator = "\n"
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		if len(line) > 0 {
+			line = "# " + line
+		} else {
+			line = "#"
+		}
+		if _, err := io.WriteString(writer, line+terminator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// append the inline comment started with " # " to the line
+func appendInlineComment(line string, comment string) string {
+	if len(comment) <= 0 {
+		return line
+	}
+	return line + " # " + comment
+}
@@ -0,0 +1,72 @@
+package ini
+
+import (
+	"io"
+	"os"
+)
+
+// Source tells Load() how to load the .ini content explicitly, instead of
+// guessing if a string is a file name or the content
+type Source interface {
+	load(ini *Ini) error
+}
+
+type fileSource struct {
+	name     string
+	optional bool
+}
+
+func (s fileSource) load(ini *Ini) error {
+	err := ini.LoadFileE(s.name)
+	if s.optional && os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// FileSource loads the .ini file, a missing file is an error
+func FileSource(name string) Source {
+	return fileSource{name: name}
+}
+
+// OptionalFileSource loads the .ini file if it exists, a missing file is
+// skipped but the other errors are still reported
+func OptionalFileSource(name string) Source {
+	return fileSource{name: name, optional: true}
+}
+
+type contentSource string
+
+func (s contentSource) load(ini *Ini) error {
+	return ini.LoadStringE(string(s))
+}
+
+// ContentSource loads the string in .ini format, it is never taken as a
+// file name
+func ContentSource(content string) Source {
+	return contentSource(content)
+}
+
+type bytesSource []byte
+
+func (s bytesSource) load(ini *Ini) error {
+	return ini.LoadBytesE(s)
+}
+
+// BytesSource loads the byte array in .ini format
+func BytesSource(content []byte) Source {
+	return bytesSource(content)
+}
+
+type readerSource struct {
+	reader io.Reader
+}
+
+func (s readerSource) load(ini *Ini) error {
+	return ini.LoadReaderE(s.reader)
+}
+
+// ReaderSource loads the .ini content from the reader
+func ReaderSource(reader io.Reader) Source {
+	return readerSource{reader: reader}
+}
@@ -0,0 +1,543 @@
+package ini
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldTag is the parsed tags of a struct field, the tags are in following format:
+//
+//  Port  int      `ini:"port,required,omitempty" default:"8080" comment:"port to listen"`
+//  Hosts []string `ini:"hosts" delim:","`
+//
+// the name is the section name or key name, "-" means the field is ignored
+// and the delim is the delimiter of the list value of the slice field
+type fieldTag struct {
+	name       string
+	required   bool
+	omitEmpty  bool
+	defValue   string
+	hasDefault bool
+	comment    string
+	delim      string
+}
+
+// parse the tags of the field, return nil if the field should be ignored
+func parseFieldTag(field reflect.StructField) *fieldTag {
+	// unexported field, but the fields of embedded struct are still mapped
+	if len(field.PkgPath) > 0 && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+		return nil
+	}
+	tag := &fieldTag{name: field.Name}
+	options := strings.Split(field.Tag.Get("ini"), ",")
+	if options[0] == "-" {
+		return nil
+	}
+	if len(options[0]) > 0 {
+		tag.name = options[0]
+	}
+	for _, option := range options[1:] {
+		switch strings.TrimSpace(option) {
+		case "required":
+			tag.required = true
+		case "omitempty":
+			tag.omitEmpty = true
+		}
+	}
+	tag.defValue, tag.hasDefault = field.Tag.Lookup("default")
+	tag.comment = field.Tag.Get("comment")
+	tag.delim = field.Tag.Get("delim")
+	return tag
+}
+
+// MappingError describes a problem found when mapping a key to a struct field
+type MappingError struct {
+	// section name
+	Section string
+	// key name, empty if the problem is on the section
+	Key string
+	// name of the struct field
+	Field string
+	Err   error
+}
+
+func (e *MappingError) Error() string {
+	if len(e.Key) <= 0 {
+		return fmt.Sprintf("section %s to field %s: %v", e.Section, e.Field, e.Err)
+	}
+	return fmt.Sprintf("key %s in section %s to field %s: %v", e.Key, e.Section, e.Field, e.Err)
+}
+
+// return the underlying error
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// MappingErrors collects all the problems found when mapping to a struct
+type MappingErrors []*MappingError
+
+func (e MappingErrors) Error() string {
+	msgs := make([]string, 0)
+	for _, me := range e {
+		msgs = append(msgs, me.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+var errRequired = errors.New("required but not found")
+
+// return the struct value pointed by v
+func structPointer(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return rv, fmt.Errorf("a non-nil pointer to struct is required but it is %T", v)
+	}
+	return rv.Elem(), nil
+}
+
+// return the errors or nil if no error
+func mappingResult(errs MappingErrors) error {
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+/*
+Map the Ini to the struct pointed by v. The struct fields (or pointer
+to struct) are mapped to the sections and the other fields are mapped
+to the keys in the default section. The struct implementing
+encoding.TextUnmarshaler such as time.Time is mapped to a key. The name
+of section or key is the field name or the name in the "ini" tag:
+
+    type Config struct {
+        Name   string `ini:"name"`
+        Server struct {
+            Host    string        `ini:"host,required"`
+            Port    int           `ini:"port" default:"8080"`
+            Timeout time.Duration `ini:"timeout" default:"10s"`
+        } `ini:"server"`
+    }
+
+The "default" tag gives the value if the key does not exist and the
+"required" option reports an error if the section or key does not exist.
+The pointer to struct is kept unchanged if its section does not exist.
+All the problems are returned as MappingErrors
+*/
+func (ini *Ini) MapTo(v interface{}) error {
+	rv, err := structPointer(v)
+	if err != nil {
+		return err
+	}
+	errs := make(MappingErrors, 0)
+	defSection, err := ini.GetSection(ini.defaultSectionName)
+	if err != nil {
+		defSection = NewSection(ini.defaultSectionName)
+	}
+	ini.mapStruct(rv, defSection, &errs)
+	return mappingResult(errs)
+}
+
+func (ini *Ini) mapStruct(rv reflect.Value, defSection *Section, errs *MappingErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := parseFieldTag(field)
+		if tag == nil {
+			continue
+		}
+		fv := rv.Field(i)
+		if field.Anonymous && isStruct(field.Type) {
+			ini.mapStruct(structElem(fv), defSection, errs)
+			continue
+		}
+		if !isStruct(field.Type) {
+			defSection.mapField(fv, field, tag, errs)
+			continue
+		}
+		section, err := ini.GetSection(tag.name)
+		if err != nil {
+			if tag.required {
+				*errs = append(*errs, &MappingError{Section: tag.name, Field: field.Name, Err: errRequired})
+				continue
+			}
+			// the pointer is kept as nil
+			if fv.Kind() == reflect.Ptr {
+				continue
+			}
+			// the defaults still apply
+			section = NewSection(tag.name)
+		}
+		section.mapStruct(structElem(fv), errs)
+	}
+}
+
+// Map the keys in the section to the fields of the struct pointed by v,
+// see Ini.MapTo() for the tags of the fields
+func (section *Section) MapTo(v interface{}) error {
+	rv, err := structPointer(v)
+	if err != nil {
+		return err
+	}
+	errs := make(MappingErrors, 0)
+	section.mapStruct(rv, &errs)
+	return mappingResult(errs)
+}
+
+func (section *Section) mapStruct(rv reflect.Value, errs *MappingErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := parseFieldTag(field)
+		if tag == nil {
+			continue
+		}
+		if field.Anonymous && isStruct(field.Type) {
+			section.mapStruct(structElem(rv.Field(i)), errs)
+			continue
+		}
+		section.mapField(rv.Field(i), field, tag, errs)
+	}
+}
+
+// set the value of key to the field
+func (section *Section) mapField(fv reflect.Value, field reflect.StructField, tag *fieldTag, errs *MappingErrors) {
+	key := section.Key(tag.name)
+	if !section.HasKey(tag.name) {
+		if tag.required {
+			*errs = append(*errs, &MappingError{Section: section.Name, Key: tag.name, Field: field.Name, Err: errRequired})
+			return
+		}
+		if !tag.hasDefault {
+			return
+		}
+		key = newNormalKey(section, tag.name, tag.defValue)
+	}
+	if err := setFieldValue(fv, key, tag.delim); err != nil {
+		*errs = append(*errs, &MappingError{Section: section.Name, Key: tag.name, Field: field.Name, Err: err})
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// convert the value of key to the type of field and set it to the field,
+// the value of slice field is splitted by the delimiter sep
+func setFieldValue(fv reflect.Value, key Key, sep string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setFieldValue(fv.Elem(), key, sep)
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		s, err := key.Value()
+		if err != nil {
+			return err
+		}
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if fv.Type() == durationType {
+		s, err := key.Value()
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(s)
+		if err == nil {
+			fv.SetInt(int64(d))
+		}
+		return err
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		s, err := key.Value()
+		if err == nil {
+			fv.SetString(s)
+		}
+		return err
+	case reflect.Bool:
+		b, err := key.Bool()
+		if err == nil {
+			fv.SetBool(b)
+		}
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := key.Int64()
+		if err != nil {
+			return err
+		}
+		if fv.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", i, fv.Type())
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := key.Uint64()
+		if err != nil {
+			return err
+		}
+		if fv.OverflowUint(i) {
+			return fmt.Errorf("value %d overflows %s", i, fv.Type())
+		}
+		fv.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := key.Float64()
+		if err != nil {
+			return err
+		}
+		if fv.OverflowFloat(f) {
+			return fmt.Errorf("value %v overflows %s", f, fv.Type())
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		values := key.Values()
+		//the list value of a key without multiple values
+		if len(values) <= 1 {
+			values = key.Strings(sep)
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, value := range values {
+			// the value is expanded already, so it is not expanded again
+			elem := &normalKey{name: key.Name(), value: value, expanded: []string{value}}
+			err := setFieldValue(slice.Index(i), elem, sep)
+			if err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// return true if the type is struct or pointer to struct, the struct
+// implementing encoding.TextUnmarshaler or encoding.TextMarshaler is a
+// value instead of a section
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	pt := reflect.PtrTo(t)
+	return t.Kind() == reflect.Struct && !pt.Implements(textUnmarshalerType) && !pt.Implements(textMarshalerType)
+}
+
+// return the struct value, the pointer is allocated if it is nil
+func structElem(fv reflect.Value) reflect.Value {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return fv.Elem()
+	}
+	return fv
+}
+
+/*
+Load the .ini from the source and map it to the struct pointed by v, the
+source can be any one supported by Load(), see Ini.MapTo() for the
+mapping of the struct fields:
+
+    var cfg Config
+    err := ini.Unmarshal( "./my.ini", &cfg )
+*/
+func Unmarshal(source interface{}, v interface{}) error {
+	ini, err := LoadE(source)
+	if err != nil {
+		return err
+	}
+	return ini.MapTo(v)
+}
+
+/*
+Add the fields of the struct pointed by v (or the struct itself) to the
+Ini, it is the reverse of MapTo(). The struct fields (or pointer to struct)
+are added as sections and the other fields are added as the keys in the
+default section. The struct implementing encoding.TextMarshaler such as
+time.Time is added as a key. Besides the "ini" tag, the "omitempty" option
+skips the field with zero value and the "comment" tag gives the comment of
+the section or key:
+
+    type Config struct {
+        Name   string `ini:"name,omitempty"`
+        Server struct {
+            Host string `ini:"host" comment:"the host name"`
+            Port int    `ini:"port"`
+        } `ini:"server" comment:"the server settings"`
+    }
+*/
+func (ini *Ini) ReflectFrom(v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	ini.reflectStruct(rv)
+	return nil
+}
+
+func (ini *Ini) reflectStruct(rv reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := parseFieldTag(field)
+		if tag == nil {
+			continue
+		}
+		fv := rv.Field(i)
+		if !isStruct(field.Type) {
+			if !isEmptyField(fv, tag) {
+				ini.NewSection(ini.defaultSectionName).reflectField(fv, tag)
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			continue
+		}
+		if field.Anonymous {
+			ini.reflectStruct(reflect.Indirect(fv))
+			continue
+		}
+		section := ini.NewSection(tag.name)
+		if len(tag.comment) > 0 {
+			section.SetComment(tag.comment)
+		}
+		section.reflectStruct(reflect.Indirect(fv))
+	}
+}
+
+// Add the fields of the struct pointed by v (or the struct itself) to the
+// section as keys, see Ini.ReflectFrom() for the tags of the fields
+func (section *Section) ReflectFrom(v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	section.reflectStruct(rv)
+	return nil
+}
+
+func (section *Section) reflectStruct(rv reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := parseFieldTag(field)
+		if tag == nil {
+			continue
+		}
+		fv := rv.Field(i)
+		if field.Anonymous && isStruct(field.Type) {
+			if fv.Kind() != reflect.Ptr || !fv.IsNil() {
+				section.reflectStruct(reflect.Indirect(fv))
+			}
+			continue
+		}
+		if !isEmptyField(fv, tag) {
+			section.reflectField(fv, tag)
+		}
+	}
+}
+
+// add the field as a key to the section
+func (section *Section) reflectField(fv reflect.Value, tag *fieldTag) {
+	section.Add(tag.name, fieldValue(fv, tag.delim))
+	if len(tag.comment) > 0 {
+		section.keyValues[tag.name].SetComment(tag.comment)
+	}
+}
+
+// return true if the field should not be added
+func isEmptyField(fv reflect.Value, tag *fieldTag) bool {
+	if fv.Kind() == reflect.Ptr && fv.IsNil() {
+		return true
+	}
+	return tag.omitEmpty && fv.IsZero()
+}
+
+// convert the field value to the string value of key, the elements of
+// slice or array are joined with the delimiter sep
+func fieldValue(fv reflect.Value, sep string) string {
+	fv = reflect.Indirect(fv)
+	if m, ok := textMarshaler(fv); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	if fv.Type() == durationType {
+		return time.Duration(fv.Int()).String()
+	}
+	switch fv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(fv.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'g', -1, 64)
+	case reflect.Slice, reflect.Array:
+		return joinList(sliceValues(fv), sep)
+	}
+	return fmt.Sprint(fv.Interface())
+}
+
+// get the encoding.TextMarshaler of the value, e.g. time.Time
+func textMarshaler(fv reflect.Value) (encoding.TextMarshaler, bool) {
+	if fv.Type().Implements(textMarshalerType) {
+		return fv.Interface().(encoding.TextMarshaler), true
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textMarshalerType) {
+		return fv.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
+
+// convert the elements of the slice or array to string
+func sliceValues(rv reflect.Value) []string {
+	r := make([]string, 0)
+	for i := 0; i < rv.Len(); i++ {
+		r = append(r, fieldValue(rv.Index(i), ""))
+	}
+	return r
+}
+
+// return the struct value of v, v is a struct or a non-nil pointer to struct
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return rv, fmt.Errorf("a struct or a non-nil pointer to struct is required but it is %T", v)
+	}
+	return rv, nil
+}
+
+// create a Ini from the struct, see Ini.ReflectFrom() for the tags of the fields
+func ReflectFrom(v interface{}) (*Ini, error) {
+	ini := NewIni()
+	ini.SetDefaultSectionName(defaultSectionName)
+	if err := ini.ReflectFrom(v); err != nil {
+		return nil, err
+	}
+	return ini, nil
+}
+
+// convert the struct to .ini format content, see Ini.ReflectFrom() for the
+// tags of the fields
+func Marshal(v interface{}) ([]byte, error) {
+	ini, err := ReflectFrom(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0))
+	if err = ini.Write(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
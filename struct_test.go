@@ -0,0 +1,187 @@
+package ini
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testServerConfig struct {
+	Host    string        `ini:"host,required"`
+	Port    uint16        `ini:"port" default:"8080"`
+	Timeout time.Duration `ini:"timeout" default:"10s"`
+	Debug   bool          `ini:"debug"`
+	Ratio   *float64      `ini:"ratio"`
+	Ignored string        `ini:"-"`
+}
+
+type testConfig struct {
+	Name     string            `ini:"name"`
+	Server   testServerConfig  `ini:"server"`
+	Database *testServerConfig `ini:"database"`
+}
+
+func TestMapTo(t *testing.T) {
+	data := `name = test
+[server]
+host = localhost
+debug = yes
+ratio = 0.5
+Ignored = value
+[database]
+host = db
+port = 3306
+timeout = 1m`
+	var cfg testConfig
+	if err := Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "test" || cfg.Server.Host != "localhost" || cfg.Server.Port != 8080 ||
+		cfg.Server.Timeout != 10*time.Second || !cfg.Server.Debug ||
+		cfg.Server.Ratio == nil || *cfg.Server.Ratio != 0.5 || cfg.Server.Ignored != "" {
+		t.Errorf("fail to map the server section: %+v", cfg.Server)
+	}
+	if cfg.Database == nil || cfg.Database.Host != "db" || cfg.Database.Port != 3306 || cfg.Database.Timeout != time.Minute {
+		t.Errorf("fail to map the database section: %+v", cfg.Database)
+	}
+}
+
+func TestMapToErrors(t *testing.T) {
+	data := "[server]\nport = 70000\ntimeout = forever"
+	var cfg testConfig
+	err := Unmarshal(data, &cfg)
+	errs, ok := err.(MappingErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("wrong errors: %v", err)
+	}
+	if errs[0].Key != "host" || errs[1].Key != "port" || errs[2].Key != "timeout" || cfg.Database != nil {
+		t.Errorf("wrong errors: %v", err)
+	}
+
+	section := NewSection("test")
+	if err := section.MapTo(cfg); err == nil {
+		t.Error("non-pointer is accepted")
+	}
+}
+
+type testMarshalConfig struct {
+	Name    string `ini:"name" comment:"name of the service"`
+	Version string `ini:"version,omitempty"`
+	Server  struct {
+		Host    string        `ini:"host"`
+		Port    int           `ini:"listen_port"`
+		Timeout time.Duration `ini:"timeout"`
+		Debug   bool          `ini:"debug,omitempty"`
+		Ratio   float32       `ini:"ratio"`
+	} `ini:"server" comment:"the server settings"`
+	Database *testServerConfig `ini:"database"`
+}
+
+func TestMarshal(t *testing.T) {
+	var cfg testMarshalConfig
+	cfg.Name = "test"
+	cfg.Server.Host = "localhost"
+	cfg.Server.Port = 80
+	cfg.Server.Timeout = 90 * time.Second
+	cfg.Server.Ratio = 0.1
+	b, err := Marshal(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `[default]
+# name of the service
+name=test
+# the server settings
+[server]
+host=localhost
+listen_port=80
+timeout=1m30s
+ratio=0.1
+`
+	if string(b) != expect {
+		t.Errorf("wrong marshal result:\n%s", string(b))
+	}
+
+	var cfg2 testMarshalConfig
+	if err := Unmarshal(b, &cfg2); err != nil || cfg2 != cfg {
+		t.Errorf("fail to unmarshal the marshal result: %v", err)
+	}
+
+	if _, err := Marshal("test"); err == nil {
+		t.Error("non-struct is accepted")
+	}
+}
+
+func TestSliceField(t *testing.T) {
+	type config struct {
+		Hosts []string `ini:"hosts"`
+		Ports []uint16 `ini:"ports" delim:" "`
+	}
+	var cfg config
+	if err := Unmarshal("hosts = a, \"b, c\"\nports = 80 443", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Hosts, []string{"a", "b, c"}) || !reflect.DeepEqual(cfg.Ports, []uint16{80, 443}) {
+		t.Errorf("fail to map the slice fields: %+v", cfg)
+	}
+	b, _ := Marshal(&cfg)
+	if string(b) != "[default]\nhosts=a, \"b, c\"\nports=80 443\n" {
+		t.Errorf("fail to marshal the slice fields:\n%s", string(b))
+	}
+}
+
+func TestSliceFieldNotExpanded(t *testing.T) {
+	type config struct {
+		Values []string `ini:"v"`
+	}
+	t.Setenv("INI_TEST_SECRET", "SECRET")
+
+	ini := NewIni()
+	ini.SetResolver(NoopResolver{})
+	ini.Load("v = $INI_TEST_SECRET, b")
+	var cfg config
+	if err := ini.MapTo(&cfg); err != nil || !reflect.DeepEqual(cfg.Values, []string{"$INI_TEST_SECRET", "b"}) {
+		t.Errorf("the elements should not be expanded with NoopResolver: %v, %v", cfg.Values, err)
+	}
+
+	ini = NewIni()
+	ini.Load("v = $$INI_TEST_SECRET, b")
+	if err := ini.MapTo(&cfg); err != nil || !reflect.DeepEqual(cfg.Values, []string{"$INI_TEST_SECRET", "b"}) {
+		t.Errorf("the elements should not be expanded twice: %v, %v", cfg.Values, err)
+	}
+}
+
+func TestTimeField(t *testing.T) {
+	type config struct {
+		Start time.Time  `ini:"start"`
+		End   *time.Time `ini:"end"`
+	}
+	var cfg config
+	if err := Unmarshal("start = 2024-01-02T03:04:05Z\nend = 2024-02-03T04:05:06Z", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	end := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	if !cfg.Start.Equal(start) || cfg.End == nil || !cfg.End.Equal(end) {
+		t.Errorf("fail to map the time fields: %+v", cfg)
+	}
+	if err := Unmarshal("start = yesterday", &cfg); err == nil {
+		t.Errorf("the bad time should be reported")
+	}
+}
+
+func TestMarshalTimeField(t *testing.T) {
+	type config struct {
+		Start time.Time `ini:"start"`
+		Name  string    `ini:"name"`
+	}
+	cfg := config{Start: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Name: "test"}
+	b, err := Marshal(&cfg)
+	if err != nil || string(b) != "[default]\nstart=2024-01-02T03\\:04\\:05Z\nname=test\n" {
+		t.Fatalf("fail to marshal the time field: %v\n%s", err, string(b))
+	}
+	var cfg2 config
+	if err := Unmarshal(b, &cfg2); err != nil || !cfg2.Start.Equal(cfg.Start) {
+		t.Errorf("fail to unmarshal the marshal result: %+v, %v", cfg2, err)
+	}
+}
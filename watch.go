@@ -0,0 +1,277 @@
+package ini
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchedFile is a file or glob pattern loaded by an Ini
+type watchedFile struct {
+	fsys fs.FS
+	name string
+}
+
+// the interval of polling the files if the interval is not positive
+const defaultWatchInterval = time.Second
+
+// record the file or glob pattern loaded to watch it if the ini is watched
+func (ini *Ini) watchFile(fsys fs.FS, name string) {
+	if !ini.watchFiles {
+		return
+	}
+	for _, f := range ini.files {
+		if f.name == name && sameFS(f.fsys, fsys) {
+			return
+		}
+	}
+	ini.files = append(ini.files, watchedFile{fsys: fsys, name: name})
+}
+
+// check if the file systems are the same one, the file systems of the
+// types not comparable such as fstest.MapFS are compared by the pointer
+func sameFS(a fs.FS, b fs.FS) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb {
+		return false
+	}
+	if ta.Comparable() {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch va.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Func, reflect.Ptr, reflect.Chan:
+		return va.Pointer() == vb.Pointer()
+	}
+	return false
+}
+
+// the state of a watched file, a missing file has the zero state
+type fileState struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// get the states of the files matched by the watched files and patterns,
+// the key of a state is the type of the file system and the file name. The
+// state in known is used instead of checking the file again if it exists
+func watchedStates(files []watchedFile, known map[string]fileState) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, f := range files {
+		names := []string{f.name}
+		if strings.ContainsAny(f.name, "*?[") {
+			names, _ = fs.Glob(f.fsys, f.name)
+		}
+		for _, name := range names {
+			key := fmt.Sprintf("%T:%s", f.fsys, name)
+			if state, ok := known[key]; ok {
+				states[key] = state
+				continue
+			}
+			var state fileState
+			if info, err := fs.Stat(f.fsys, name); err == nil {
+				state.modTime = info.ModTime()
+				state.size = info.Size()
+				if content, err := fs.ReadFile(f.fsys, name); err == nil {
+					state.hash = sha256.Sum256(content)
+				}
+			}
+			states[key] = state
+		}
+	}
+	return states
+}
+
+// ChangeType tells how a key is changed by a reload
+type ChangeType int
+
+const (
+	KeyAdded ChangeType = iota
+	KeyRemoved
+	KeyModified
+)
+
+// Change is a key changed by a reload
+type Change struct {
+	Section string
+	Key     string
+	Type    ChangeType
+}
+
+// Watcher reloads the .ini when the files it is loaded from are changed,
+// including the included files and the files matched by the glob patterns.
+// The files are polled for the changes of the modification time, size and
+// content hash, and the reloaded ini replaces the current one atomically if
+// it is loaded without error, otherwise the current one is kept
+type Watcher struct {
+	config  *SafeIni
+	sources []interface{}
+	// serializes the polls and guards the states of the files
+	pollMu sync.Mutex
+	states map[string]fileState
+	// guards the callbacks and the polling goroutine
+	mu       sync.Mutex
+	onChange []func(changes []Change)
+	onError  []func(err error)
+	// closed to stop the polling
+	stop chan struct{}
+	done chan struct{}
+}
+
+// load the sources into the ini and watch the files loaded. The ini has
+// the settings for the reloads and a new Ini is created if it is nil. The
+// sources are loaded again on every reload, so they should be the files or
+// the content instead of io.Reader, and FileSource() should be used for the
+// files so a deleted file is not taken as the content
+func NewWatcher(ini *Ini, sources ...interface{}) (*Watcher, error) {
+	if ini == nil {
+		ini = NewIni()
+	}
+	ini.watchFiles = true
+	if err := ini.LoadE(sources...); err != nil {
+		return nil, err
+	}
+	return &Watcher{config: NewSafeIni(ini),
+		sources: sources,
+		states:  watchedStates(ini.files, nil)}, nil
+}
+
+// get the current ini, it is replaced by the reloads
+func (w *Watcher) Ini() *SafeIni {
+	return w.config
+}
+
+// add a callback called with the changed keys after a reload, it is not
+// called if no key is changed
+func (w *Watcher) OnChange(fn func(changes []Change)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, fn)
+}
+
+// add a callback called with the error if a reload fails
+func (w *Watcher) OnError(fn func(err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = append(w.onError, fn)
+}
+
+// poll the files in a goroutine with the interval until Stop() is called,
+// the interval is one second if it is not positive
+func (w *Watcher) Start(interval time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func(stop chan struct{}, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.Poll()
+			}
+		}
+	}(w.stop, w.done)
+}
+
+// stop polling the files and wait for the polling goroutine to exit
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// check the files once and reload the .ini if any file is changed, the
+// callbacks are called in the calling goroutine and Stop() must not be
+// called in them. The error of the reload is returned and also passed to
+// the error callbacks, and it is reported once until the files are changed
+// again
+func (w *Watcher) Poll() error {
+	w.pollMu.Lock()
+	defer w.pollMu.Unlock()
+	var files []watchedFile
+	var next *Ini
+	w.config.Read(func(ini *Ini) {
+		files = ini.files
+		next = ini.emptyCopy()
+	})
+	states := watchedStates(files, nil)
+	if reflect.DeepEqual(states, w.states) {
+		return nil
+	}
+	w.states = states
+	w.mu.Lock()
+	onChange := append([]func([]Change){}, w.onChange...)
+	onError := append([]func(error){}, w.onError...)
+	w.mu.Unlock()
+	if err := next.LoadE(w.sources...); err != nil {
+		for _, fn := range onError {
+			fn(err)
+		}
+		return err
+	}
+	// the files loaded may be changed by the include directives. The states
+	// got before loading are kept, so a file changed after it is checked is
+	// reloaded by the next poll, and only the new files are checked
+	w.states = watchedStates(next.files, states)
+	changes := diffIni(w.config.swap(next), next)
+	if len(changes) > 0 {
+		for _, fn := range onChange {
+			fn(changes)
+		}
+	}
+	return nil
+}
+
+// get the keys changed from the old ini to the new one, sorted by section
+// and key
+func diffIni(old *Ini, new *Ini) []Change {
+	changes := make([]Change, 0)
+	for _, section := range old.Sections() {
+		for _, key := range section.Keys() {
+			if !new.HasKey(section.Name, key.Name()) {
+				changes = append(changes, Change{Section: section.Name, Key: key.Name(), Type: KeyRemoved})
+			}
+		}
+	}
+	for _, section := range new.Sections() {
+		for _, key := range section.Keys() {
+			if !old.HasKey(section.Name, key.Name()) {
+				changes = append(changes, Change{Section: section.Name, Key: key.Name(), Type: KeyAdded})
+				continue
+			}
+			oldSection, _ := old.GetSection(section.Name)
+			if !reflect.DeepEqual(oldSection.Key(key.Name()).Values(), key.Values()) {
+				changes = append(changes, Change{Section: section.Name, Key: key.Name(), Type: KeyModified})
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return changes[i].Section < changes[j].Section
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
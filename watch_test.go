@@ -0,0 +1,133 @@
+package ini
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.ini")
+	common := filepath.Join(dir, "common.ini")
+	os.WriteFile(main, []byte("[db]\nhost = localhost\n!include common.ini\n!includedir conf.d\n"), 0644)
+	os.WriteFile(common, []byte("port = 5432\nuser = root\n"), 0644)
+	os.Mkdir(filepath.Join(dir, "conf.d"), 0755)
+
+	ini := NewIni()
+	ini.SetIncludeFiles(true)
+	w, err := NewWatcher(ini, FileSource(main))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []Change
+	var errs []error
+	w.OnChange(func(c []Change) {
+		changes = c
+	})
+	w.OnError(func(err error) {
+		errs = append(errs, err)
+	})
+	if err := w.Poll(); err != nil || changes != nil {
+		t.Fatalf("nothing should be reloaded: %v, %v", err, changes)
+	}
+
+	// the included file is changed
+	os.WriteFile(common, []byte("port = 6543\ntimeout = 10\n"), 0644)
+	if err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	expect := []Change{{"db", "port", KeyModified}, {"db", "timeout", KeyAdded}, {"db", "user", KeyRemoved}}
+	if !reflect.DeepEqual(changes, expect) {
+		t.Errorf("wrong changes: %v", changes)
+	}
+	if v, _ := w.Ini().GetInt("db", "port"); v != 6543 {
+		t.Errorf("the ini should be reloaded: %d", v)
+	}
+
+	// the last good ini is kept if the reload fails
+	os.WriteFile(main, []byte("[db\nhost = db\n"), 0644)
+	if err := w.Poll(); err == nil || len(errs) != 1 {
+		t.Errorf("the reload should fail: %v, %v", err, errs)
+	}
+	if v, _ := w.Ini().GetValue("db", "host"); v != "localhost" {
+		t.Errorf("the ini should be kept: %s", v)
+	}
+	if err := w.Poll(); err != nil || len(errs) != 1 {
+		t.Errorf("the error should be reported once: %v, %v", err, errs)
+	}
+	os.WriteFile(main, []byte("[db]\nhost = db\n!include common.ini\n!includedir conf.d\n"), 0644)
+
+	// a drop-in file is added
+	changes = nil
+	os.WriteFile(filepath.Join(dir, "conf.d", "1.ini"), []byte("[log]\nlevel = debug\n"), 0644)
+	w.Start(10 * time.Millisecond)
+	defer w.Stop()
+	for i := 0; i < 100 && !w.Ini().HasKey("log", "level"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	w.Stop()
+	expect = []Change{{"db", "host", KeyModified}, {"log", "level", KeyAdded}}
+	if !reflect.DeepEqual(changes, expect) {
+		t.Errorf("wrong changes: %v", changes)
+	}
+}
+
+func TestWatchedStates(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.ini")
+	b := filepath.Join(dir, "b.ini")
+	os.WriteFile(a, []byte("x = 1\n"), 0644)
+	os.WriteFile(b, []byte("y = 1\n"), 0644)
+	files := []watchedFile{{osFS{}, a}}
+	known := watchedStates(files, nil)
+
+	// the file is changed after it is checked and loaded
+	os.WriteFile(a, []byte("x = 2\n"), 0644)
+	files = append(files, watchedFile{osFS{}, b})
+	states := watchedStates(files, known)
+	if len(states) != 2 || !reflect.DeepEqual(states, watchedStates(files, known)) {
+		t.Fatalf("wrong states: %v", states)
+	}
+	for key, state := range known {
+		if states[key] != state {
+			t.Errorf("the known state of %s should be kept", key)
+		}
+	}
+	if reflect.DeepEqual(states, watchedStates(files, nil)) {
+		t.Errorf("the change after the check should be found by the next poll")
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.ini")
+	os.WriteFile(file, []byte("x = 1\n"), 0644)
+
+	ini := NewIni()
+	ini.LoadFile(file)
+	if len(ini.files) != 0 {
+		t.Errorf("the files should not be recorded if the ini is not watched: %v", ini.files)
+	}
+	w, err := NewWatcher(nil, FileSource(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"b.ini": {Data: []byte("y = 1\n")}}
+	w.Ini().Update(func(ini *Ini) error {
+		for i := 0; i < 3; i++ {
+			ini.LoadFile(file)
+			ini.LoadFS(fsys, "b.ini")
+		}
+		ini.LoadFS(fstest.MapFS{"b.ini": {Data: []byte("y = 2\n")}}, "b.ini")
+		if len(ini.files) != 3 {
+			t.Errorf("the files should be recorded once: %v", ini.files)
+		}
+		return nil
+	})
+	w.Start(0)
+	w.Stop()
+}